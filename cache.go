@@ -0,0 +1,239 @@
+package et
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats reports the template cache usage of an Environment
+type Stats struct {
+	// Entries is the number of cached templates
+	Entries int
+
+	// Bytes is the approximate size of the cached templates, see Snapshot.Size
+	Bytes int64
+
+	// Hits counts loads served from the cache
+	Hits uint64
+
+	// Misses counts loads of templates which were not cached, were stale or had expired
+	Misses uint64
+
+	// Reparses counts parses of templates which were already cached but stale
+	Reparses uint64
+
+	// Evictions counts templates dropped from the cache to respect its limits or TTL
+	Evictions uint64
+
+	// Parses counts all parses, successful or not
+	Parses uint64
+
+	// ParseTime is the total and MaxParseTime the longest duration of the parses
+	ParseTime    time.Duration
+	MaxParseTime time.Duration
+}
+
+type stats struct {
+	hits         atomic.Uint64
+	misses       atomic.Uint64
+	reparses     atomic.Uint64
+	evictions    atomic.Uint64
+	parses       atomic.Uint64
+	parseTime    atomic.Int64
+	maxParseTime atomic.Int64
+}
+
+func (s *stats) parsed(d time.Duration) {
+	s.parses.Add(1)
+	s.parseTime.Add(int64(d))
+	for {
+		m := s.maxParseTime.Load()
+		if int64(d) <= m || s.maxParseTime.CompareAndSwap(m, int64(d)) {
+			return
+		}
+	}
+}
+
+type cacheEntry struct {
+	key     string
+	wrapper *TemplateWrapper
+	size    int64
+	stored  int64
+
+	// recent and age are the elements of the entry in the recency and in the age lists,
+	// nil once the entry is dropped
+	recent *list.Element
+	age    *list.Element
+}
+
+// cache holds the parsed templates of an Environment. Lookups are lock-free, the recency of
+// a hit is recorded only when the cache is bounded. The limits are enforced on insertion by
+// evicting the least recently used templates.
+//
+// Every invalidation starts a new epoch. A parse which started in an earlier epoch than the
+// last invalidation of one of its templates may have read stale sources, it is not stored.
+type cache struct {
+	entries     sync.Map
+	count       atomic.Int64
+	bytes       atomic.Int64
+	epoch       atomic.Uint64
+	invalidated map[string]uint64
+
+	// exprs are the parsed extends expressions, dropped with the cache as they depend on the
+	// delimiters and functions of the Environment
+	exprs sync.Map
+
+	// recent orders the entries from the most to the least recently used, age from the most
+	// to the least recently stored
+	recent list.List
+	age    list.List
+	mu     sync.Mutex
+}
+
+func (c *cache) load(key string) (*cacheEntry, bool) {
+	if v, ok := c.entries.Load(key); ok {
+		return v.(*cacheEntry), true
+	}
+	return nil, false
+}
+
+// touch marks the entry as the most recently used
+func (c *cache) touch(entry *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry.recent != nil {
+		c.recent.MoveToFront(entry.recent)
+	}
+}
+
+// store caches the wrapper parsed since epoch, unless one of its templates was invalidated in
+// the meantime. It reports whether the wrapper was stored.
+func (c *cache) store(key string, wrapper *TemplateWrapper, now int64, epoch uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.changed(wrapper, epoch) {
+		if v, ok := c.entries.Load(key); ok && v.(*cacheEntry).wrapper == wrapper {
+			c.remove(v.(*cacheEntry))
+		}
+		return false
+	}
+
+	entry := &cacheEntry{key: key, wrapper: wrapper, stored: now}
+	if s := wrapper.Snapshot(); s != nil {
+		entry.size = s.Size()
+	}
+
+	if v, ok := c.entries.Load(key); ok {
+		c.remove(v.(*cacheEntry))
+	}
+	c.entries.Store(key, entry)
+	entry.recent = c.recent.PushFront(entry)
+	entry.age = c.age.PushFront(entry)
+	c.count.Add(1)
+	c.bytes.Add(entry.size)
+	return true
+}
+
+func (c *cache) delete(entry *cacheEntry) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.remove(entry)
+}
+
+// remove drops the entry unless it was replaced or dropped already, c.mu must be held
+func (c *cache) remove(entry *cacheEntry) bool {
+	if entry.recent == nil || !c.entries.CompareAndDelete(entry.key, entry) {
+		return false
+	}
+
+	c.recent.Remove(entry.recent)
+	c.age.Remove(entry.age)
+	entry.recent, entry.age = nil, nil
+	c.count.Add(-1)
+	c.bytes.Add(-entry.size)
+	return true
+}
+
+// invalidate starts a new epoch in which names changed
+func (c *cache) invalidate(names ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	epoch := c.epoch.Add(1)
+	if c.invalidated == nil {
+		c.invalidated = make(map[string]uint64)
+	}
+	for _, name := range names {
+		c.invalidated[name] = epoch
+	}
+}
+
+// changed reports whether a template of the wrapper was invalidated after epoch
+func (c *cache) changed(wrapper *TemplateWrapper, epoch uint64) bool {
+	if c.invalidated[wrapper.name] > epoch {
+		return true
+	}
+
+	s := wrapper.Snapshot()
+	if s == nil {
+		return false
+	}
+	for name := range s.names {
+		if c.invalidated[name] > epoch {
+			return true
+		}
+	}
+	for name := range s.missing {
+		if c.invalidated[name] > epoch {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *cache) expired(entry *cacheEntry, now int64, ttl time.Duration) bool {
+	return ttl > 0 && now-entry.stored >= int64(ttl)
+}
+
+// prune drops the expired templates, then the least recently used ones until the cache fits
+// maxEntries and maxBytes. Zero limits are unlimited, and the template stored under keep is
+// never evicted. It returns the number of evicted templates.
+func (c *cache) prune(maxEntries int, maxBytes int64, ttl time.Duration, now int64, keep string) (evicted uint64) {
+	if maxEntries <= 0 && maxBytes <= 0 && ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if ttl > 0 {
+		for e := c.age.Back(); e != nil; {
+			entry := e.Value.(*cacheEntry)
+			if !c.expired(entry, now, ttl) {
+				break
+			}
+			e = e.Prev()
+			if entry.key != keep && c.remove(entry) {
+				evicted++
+			}
+		}
+	}
+
+	for e := c.recent.Back(); e != nil && c.exceeds(maxEntries, maxBytes); {
+		entry := e.Value.(*cacheEntry)
+		e = e.Prev()
+		if entry.key != keep && c.remove(entry) {
+			evicted++
+		}
+	}
+	return
+}
+
+func (c *cache) exceeds(maxEntries int, maxBytes int64) bool {
+	return (maxEntries > 0 && c.count.Load() > int64(maxEntries)) || (maxBytes > 0 && c.bytes.Load() > maxBytes)
+}
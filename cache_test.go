@@ -0,0 +1,115 @@
+package et_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	et "github.com/gowool/extends-template"
+)
+
+func newCacheLoader() *et.MemoryLoader {
+	return et.NewMemoryLoader(map[string][]byte{
+		"layout.html": []byte(`<body>{{block "content" .}}{{end}}</body>`),
+		"a.html":      []byte(`{{extends "layout.html"}}{{define "content"}}a{{end}}`),
+		"b.html":      []byte(`{{extends "layout.html"}}{{define "content"}}b{{end}}`),
+		"c.html":      []byte(`{{extends "layout.html"}}{{define "content"}}c{{end}}`),
+	})
+}
+
+func TestEnvironment_Stats(t *testing.T) {
+	loader := newCacheLoader()
+	env := et.NewEnvironment(loader)
+
+	a1, err := env.Load(context.TODO(), "a.html")
+	assert.NoError(t, err)
+	a2, err := env.Load(context.TODO(), "a.html")
+	assert.NoError(t, err)
+	assert.Same(t, a1, a2)
+
+	loader.Add("a.html", []byte(`{{extends "layout.html"}}{{define "content"}}A{{end}}`))
+	_, err = env.Load(context.TODO(), "a.html")
+	assert.NoError(t, err)
+
+	_, err = env.Load(context.TODO(), "missing.html")
+	assert.Error(t, err)
+
+	stats := env.Stats()
+	assert.Equal(t, 1, stats.Entries)
+	assert.Equal(t, int64(len(`{{extends "layout.html"}}{{define "content"}}A{{end}}`)+len(`<body>{{block "content" .}}{{end}}</body>`)), stats.Bytes)
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, uint64(3), stats.Misses)
+	assert.Equal(t, uint64(1), stats.Reparses)
+	assert.Equal(t, uint64(3), stats.Parses)
+	assert.Equal(t, uint64(0), stats.Evictions)
+	assert.Positive(t, stats.ParseTime)
+	assert.GreaterOrEqual(t, stats.ParseTime, stats.MaxParseTime)
+}
+
+func TestEnvironment_CacheLimitEntries(t *testing.T) {
+	env := et.NewEnvironment(newCacheLoader()).CacheLimit(2, 0)
+
+	a, _ := env.Load(context.TODO(), "a.html")
+	_, _ = env.Load(context.TODO(), "b.html")
+	_, _ = env.Load(context.TODO(), "a.html")
+	_, _ = env.Load(context.TODO(), "c.html")
+
+	stats := env.Stats()
+	assert.Equal(t, 2, stats.Entries)
+	assert.Equal(t, uint64(1), stats.Evictions)
+
+	// b was the least recently used template
+	a1, _ := env.Load(context.TODO(), "a.html")
+	assert.Same(t, a, a1)
+	_, _ = env.Load(context.TODO(), "b.html")
+
+	stats = env.Stats()
+	assert.Equal(t, uint64(2), stats.Hits)
+	assert.Equal(t, uint64(4), stats.Misses)
+	assert.Equal(t, uint64(2), stats.Evictions)
+}
+
+func TestEnvironment_CacheLimitBytes(t *testing.T) {
+	env := et.NewEnvironment(newCacheLoader())
+
+	w, _ := env.Load(context.TODO(), "a.html")
+	size := w.Snapshot().Size()
+
+	env.CacheLimit(0, 2*size)
+	_, _ = env.Load(context.TODO(), "b.html")
+	_, _ = env.Load(context.TODO(), "c.html")
+
+	stats := env.Stats()
+	assert.Equal(t, 2, stats.Entries)
+	assert.Equal(t, 2*size, stats.Bytes)
+	assert.Equal(t, uint64(1), stats.Evictions)
+
+	// the template just parsed is kept even if it alone exceeds the limit
+	env.CacheLimit(0, 1)
+	_, _ = env.Load(context.TODO(), "a.html")
+
+	stats = env.Stats()
+	assert.Equal(t, 1, stats.Entries)
+	assert.Equal(t, uint64(3), stats.Evictions)
+}
+
+func TestEnvironment_CacheTTL(t *testing.T) {
+	env := et.NewEnvironment(newCacheLoader()).CacheTTL(20 * time.Millisecond)
+
+	a, _ := env.Load(context.TODO(), "a.html")
+	a1, _ := env.Load(context.TODO(), "a.html")
+	assert.Same(t, a, a1)
+
+	time.Sleep(30 * time.Millisecond)
+
+	a2, err := env.Load(context.TODO(), "a.html")
+	assert.NoError(t, err)
+	assert.NotSame(t, a, a2)
+
+	stats := env.Stats()
+	assert.Equal(t, 1, stats.Entries)
+	assert.Equal(t, uint64(1), stats.Evictions)
+	assert.Equal(t, uint64(0), stats.Reparses)
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	et "github.com/gowool/extends-template"
+)
+
+var checkCommand = command{
+	name:  "check",
+	usage: "check [patterns...]",
+	setup: func(*flag.FlagSet) runFunc {
+		return func(ctx context.Context, env *et.Environment, args []string, stdout io.Writer) error {
+			if err := env.Warmup(ctx, args...); err != nil {
+				return err
+			}
+
+			_, err := fmt.Fprintf(stdout, "ok: %d templates\n", env.Stats().Entries)
+			return err
+		}
+	},
+}
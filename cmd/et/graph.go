@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	et "github.com/gowool/extends-template"
+)
+
+var graphCommand = command{
+	name:  "graph",
+	usage: "graph [-format dot|json] [patterns...]",
+	setup: func(fs *flag.FlagSet) runFunc {
+		format := fs.String("format", "dot", "output `format`, dot or json")
+
+		return func(ctx context.Context, env *et.Environment, args []string, stdout io.Writer) error {
+			var write func(g *et.Graph, w io.Writer) error
+			switch *format {
+			case "dot":
+				write = (*et.Graph).WriteDOT
+			case "json":
+				write = (*et.Graph).WriteJSON
+			default:
+				return fmt.Errorf("unknown format %q", *format)
+			}
+
+			g, err := env.Graph(ctx, args...)
+			if g == nil {
+				return err
+			}
+			if err1 := write(g, stdout); err1 != nil {
+				return err1
+			}
+			return err
+		}
+	},
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	et "github.com/gowool/extends-template"
+)
+
+var lintCommand = command{
+	name:  "lint",
+	usage: "lint [-format text|json] [patterns...]",
+	setup: func(fs *flag.FlagSet) runFunc {
+		format := fs.String("format", "text", "output `format`, text or json")
+
+		return func(ctx context.Context, env *et.Environment, args []string, stdout io.Writer) error {
+			if *format != "text" && *format != "json" {
+				return fmt.Errorf("unknown format %q", *format)
+			}
+
+			issues, err := et.Lint(ctx, env, args...)
+			if err != nil {
+				return err
+			}
+
+			if *format == "json" {
+				enc := json.NewEncoder(stdout)
+				enc.SetIndent("", "  ")
+				err = enc.Encode(issues)
+			} else {
+				for _, issue := range issues {
+					if _, err = fmt.Fprintln(stdout, issue); err != nil {
+						break
+					}
+				}
+			}
+			if err != nil {
+				return err
+			}
+
+			if len(issues) > 0 {
+				return fmt.Errorf("%d issues found", len(issues))
+			}
+			return nil
+		}
+	},
+}
@@ -0,0 +1,124 @@
+// Command et works with extends-template templates outside of Go code.
+//
+// Usage:
+//
+//	et <command> [flags] [arguments]
+//
+// The commands are:
+//
+//	check    parse templates and report all errors
+//	graph    print the inheritance graph of templates as DOT or JSON
+//	lint     report likely mistakes in templates
+//	render   render a template with JSON or YAML data to stdout
+//
+// Every top-level directory of -dir is a namespace, like with et.NewFSLoaderWithNS.
+// Patterns select the entry templates, like "@main/pages/**". Without patterns, the entry
+// templates are those no other template extends or includes.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	et "github.com/gowool/extends-template"
+)
+
+type runFunc func(ctx context.Context, env *et.Environment, args []string, stdout io.Writer) error
+
+type command struct {
+	name  string
+	usage string
+
+	// setup registers the flags of the command and returns the function running it
+	setup func(fs *flag.FlagSet) runFunc
+}
+
+var commands = []command{
+	checkCommand,
+	graphCommand,
+	lintCommand,
+	renderCommand,
+}
+
+func main() {
+	if err := run(context.Background(), os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			for _, line := range strings.Split(err.Error(), "\n") {
+				fmt.Fprintln(os.Stderr, "et:", line)
+			}
+		}
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		usage(stderr)
+		return flag.ErrHelp
+	}
+
+	i := slices.IndexFunc(commands, func(c command) bool {
+		return c.name == args[0]
+	})
+	if i < 0 {
+		usage(stderr)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	cmd := commands[i]
+
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: et %s\n", cmd.usage)
+		fs.PrintDefaults()
+	}
+
+	dir := fs.String("dir", ".", "templates `directory`, each top-level directory is a namespace")
+	left := fs.String("left", "{{", "left action delimiter")
+	right := fs.String("right", "}}", "right action delimiter")
+	text := fs.Bool("text", false, "use text/template instead of html/template")
+
+	var global []string
+	fs.Func("global", "global template `name`, may be repeated", func(s string) error {
+		global = append(global, s)
+		return nil
+	})
+
+	runCommand := cmd.setup(fs)
+
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	loader, err := et.NewFSLoaderWithNS(os.DirFS(*dir))
+	if err != nil {
+		return err
+	}
+
+	var env *et.Environment
+	if *text {
+		env = et.NewTextEnvironment(loader)
+	} else {
+		env = et.NewEnvironment(loader)
+	}
+	env.Delims(*left, *right).Global(global...)
+
+	return runCommand(ctx, env, fs.Args(), stdout)
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: et <command> [flags] [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  et %s\n", c.usage)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `run "et <command> -h" for the flags of a command`)
+}
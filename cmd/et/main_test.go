@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTemplatesDir(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, code := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(code), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+var templates = map[string]string{
+	"main/layout.html":     `<body>{{block "content" .}}{{end}}</body>`,
+	"main/pages/home.html": `{{extends "layout.html"}}{{define "content"}}{{.Title}}{{end}}`,
+	"main/global.html":     `{{define "title"}}title{{end}}`,
+}
+
+func TestRun_Usage(t *testing.T) {
+	var stderr bytes.Buffer
+
+	assert.Error(t, run(context.TODO(), nil, &bytes.Buffer{}, &stderr))
+	assert.Contains(t, stderr.String(), "usage: et")
+
+	assert.Error(t, run(context.TODO(), []string{"unknown"}, &bytes.Buffer{}, &stderr))
+}
+
+func TestRun_Graph(t *testing.T) {
+	dir := newTemplatesDir(t, templates)
+
+	var stdout bytes.Buffer
+	err := run(context.TODO(), []string{"graph", "-dir", dir, "-global", "@main/global.html", "@main/pages/**"}, &stdout, &bytes.Buffer{})
+
+	if assert.NoError(t, err) {
+		assert.Contains(t, stdout.String(), `"@main/pages/home.html" -> "@main/layout.html" [label="extends"];`)
+		assert.Contains(t, stdout.String(), `"@main/layout.html" -> "@main/global.html" [label="global", style="dotted"];`)
+	}
+
+	stdout.Reset()
+	err = run(context.TODO(), []string{"graph", "-dir", dir, "-format", "json"}, &stdout, &bytes.Buffer{})
+
+	if assert.NoError(t, err) {
+		assert.Contains(t, stdout.String(), `"nodes": [`)
+	}
+
+	assert.Error(t, run(context.TODO(), []string{"graph", "-dir", dir, "-format", "svg"}, &stdout, &bytes.Buffer{}))
+}
+
+func TestRun_Render(t *testing.T) {
+	dir := newTemplatesDir(t, templates)
+	data := newTemplatesDir(t, map[string]string{
+		"data.json": `{"Title": "json"}`,
+		"data.yaml": "Title: yaml\n",
+	})
+
+	scenarios := []struct {
+		args     []string
+		expected string
+	}{
+		{args: []string{"-data", filepath.Join(data, "data.json")}, expected: "<body>json</body>"},
+		{args: []string{"-data", filepath.Join(data, "data.yaml")}, expected: "<body>yaml</body>"},
+		{args: []string{"-data", filepath.Join(data, "data.yaml"), "-block", "content"}, expected: "yaml"},
+	}
+
+	for _, s := range scenarios {
+		var stdout bytes.Buffer
+		args := append(append([]string{"render", "-dir", dir}, s.args...), "@main/pages/home.html")
+
+		if assert.NoError(t, run(context.TODO(), args, &stdout, &bytes.Buffer{})) {
+			assert.Equal(t, s.expected, stdout.String())
+		}
+	}
+
+	assert.Error(t, run(context.TODO(), []string{"render", "-dir", dir}, &bytes.Buffer{}, &bytes.Buffer{}))
+	assert.Error(t, run(context.TODO(), []string{"render", "-dir", dir, "@main/missing.html"}, &bytes.Buffer{}, &bytes.Buffer{}))
+}
+
+func TestReadData(t *testing.T) {
+	data, err := readData("-", bytes.NewBufferString(`{"a": [1, 2]}`))
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]any{"a": []any{1, 2}}, data)
+	}
+
+	data, err = readData("", nil)
+	assert.NoError(t, err)
+	assert.Nil(t, data)
+
+	_, err = readData("-", bytes.NewBufferString(`{`))
+	assert.Error(t, err)
+}
+
+func TestRun_Check(t *testing.T) {
+	dir := newTemplatesDir(t, templates)
+
+	var stdout bytes.Buffer
+	if assert.NoError(t, run(context.TODO(), []string{"check", "-dir", dir}, &stdout, &bytes.Buffer{})) {
+		assert.Equal(t, "ok: 2 templates\n", stdout.String())
+	}
+
+	dir = newTemplatesDir(t, map[string]string{
+		"main/a.html": `{{if}}`,
+		"main/b.html": `{{extends "missing.html"}}`,
+		"main/c.html": `c`,
+	})
+
+	err := run(context.TODO(), []string{"check", "-dir", dir}, &bytes.Buffer{}, &bytes.Buffer{})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "@main/a.html")
+		assert.Contains(t, err.Error(), "@main/b.html")
+		assert.NotContains(t, err.Error(), "@main/c.html")
+	}
+}
+
+func TestRun_CheckEntries(t *testing.T) {
+	// the partial renders a template defined by the page, it cannot be checked on its own
+	dir := newTemplatesDir(t, map[string]string{
+		"main/layout.html":        `<html>{{template "partials/head.html" .}}{{block "content" .}}{{end}}</html>`,
+		"main/partials/head.html": `<title>{{template "title" .}}</title>`,
+		"main/pages/home.html":    `{{extends "layout.html"}}{{define "title"}}home{{end}}{{define "content"}}home{{end}}`,
+	})
+
+	for _, args := range [][]string{{}, {"@main/pages/**"}} {
+		var stdout bytes.Buffer
+		if assert.NoError(t, run(context.TODO(), append([]string{"check", "-dir", dir}, args...), &stdout, &bytes.Buffer{}), args) {
+			assert.Equal(t, "ok: 1 templates\n", stdout.String())
+		}
+	}
+}
+
+func TestRun_Lint(t *testing.T) {
+	dir := newTemplatesDir(t, templates)
+
+	var stdout bytes.Buffer
+	assert.NoError(t, run(context.TODO(), []string{"lint", "-dir", dir, "-global", "global.html"}, &stdout, &bytes.Buffer{}))
+	assert.Empty(t, stdout.String())
+
+	err := run(context.TODO(), []string{"lint", "-dir", dir, "@main/pages/**"}, &stdout, &bytes.Buffer{})
+	if assert.Error(t, err) {
+		assert.Equal(t, "1 issues found", err.Error())
+		assert.Equal(t, "@main/global.html: unused-partial: template is not used by any matched template\n", stdout.String())
+	}
+
+	stdout.Reset()
+	_ = run(context.TODO(), []string{"lint", "-dir", dir, "-format", "json", "@main/pages/**"}, &stdout, &bytes.Buffer{})
+	assert.Contains(t, stdout.String(), `"rule": "unused-partial"`)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	et "github.com/gowool/extends-template"
+)
+
+var renderCommand = command{
+	name:  "render",
+	usage: "render [-data file] [-block name] template",
+	setup: func(fs *flag.FlagSet) runFunc {
+		data := fs.String("data", "", "JSON or YAML data `file`, - reads YAML or JSON from stdin")
+		block := fs.String("block", "", "render only the named `block` of the template")
+
+		return func(ctx context.Context, env *et.Environment, args []string, stdout io.Writer) error {
+			if len(args) != 1 {
+				fs.Usage()
+				return flag.ErrHelp
+			}
+
+			d, err := readData(*data, os.Stdin)
+			if err != nil {
+				return err
+			}
+
+			var buf bytes.Buffer
+			if *block != "" {
+				err = env.RenderBlock(ctx, &buf, args[0], *block, d)
+			} else {
+				err = env.Render(ctx, &buf, args[0], d)
+			}
+			if err != nil {
+				return err
+			}
+
+			_, err = buf.WriteTo(stdout)
+			return err
+		}
+	},
+}
+
+// readData decodes the data file, files with a .json extension as JSON and all others as YAML,
+// which JSON is a subset of
+func readData(file string, stdin io.Reader) (data any, err error) {
+	var raw []byte
+	switch file {
+	case "":
+		return nil, nil
+	case "-":
+		raw, err = io.ReadAll(stdin)
+	default:
+		raw, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(filepath.Ext(file), ".json") {
+		err = json.Unmarshal(raw, &data)
+	} else {
+		err = yaml.Unmarshal(raw, &data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("data %s: %w", file, err)
+	}
+	return data, nil
+}
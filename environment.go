@@ -3,12 +3,18 @@ package et
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"html/template"
-	"regexp"
+	htmltemplate "html/template"
+	"io"
+	"runtime"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
+	texttemplate "text/template"
+	"time"
 
 	"github.com/gowool/extends-template/internal"
 )
@@ -19,52 +25,86 @@ const (
 )
 
 type Environment struct {
-	debug      bool
-	global     []string
-	left       string
-	right      string
-	loader     Loader
-	handlers   []Handler
-	reExtends  *regexp.Regexp
-	reTemplate *regexp.Regexp
-	templates  *sync.Map
-	funcMap    template.FuncMap
-	hash       atomic.Value
-	mu         sync.Mutex
+	text        bool
+	debug       atomic.Bool
+	notifier    Notifier
+	unsubscribe func()
+	closed      atomic.Bool
+	immutable   bool
+	global      []string
+	left        string
+	right       string
+	loader      Loader
+	handlers    []Handler
+	templates   atomic.Pointer[cache]
+	maxEntries  atomic.Int64
+	maxBytes    atomic.Int64
+	ttl         atomic.Int64
+	stats       stats
+	funcMap     map[string]any
+	hash        atomic.Value
+	group       internal.Group
+	mu          sync.RWMutex
 }
 
 func NewEnvironment(loader Loader, handlers ...Handler) *Environment {
-	e := &Environment{loader: loader, handlers: handlers, funcMap: template.FuncMap{}}
+	e := &Environment{
+		loader:    loader,
+		handlers:  handlers,
+		funcMap:   map[string]any{},
+		immutable: isImmutable(loader),
+	}
+
+	if n, ok := loader.(Notifier); ok {
+		e.notifier = n
+		e.unsubscribe = n.Notify(e.notify)
+	}
 
 	return e.Delims(leftDelim, rightDelim)
 }
 
+// NewTextEnvironment returns an Environment which produces text/template sets
+// instead of html/template ones
+func NewTextEnvironment(loader Loader, handlers ...Handler) *Environment {
+	e := NewEnvironment(loader, handlers...)
+	e.text = true
+
+	return e
+}
+
 func (e *Environment) Debug(debug bool) *Environment {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	if e.debug != debug {
-		e.debug = debug
+	if e.debug.Load() != debug {
+		e.debug.Store(debug)
 		e.updateHash()
 	}
 
 	return e
 }
 
+func (e *Environment) Loader() Loader {
+	return e.loader
+}
+
+func (e *Environment) IsDebug() bool {
+	return e.debug.Load()
+}
+
 func (e *Environment) Delims(left, right string) *Environment {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
 	e.left = left
 	e.right = right
-	e.reExtends = internal.ReExtends(left, right)
-	e.reTemplate = internal.ReTemplate(left, right)
 	e.updateHash()
 
 	return e
 }
 
-func (e *Environment) Funcs(funcMap template.FuncMap) *Environment {
+// Funcs accepts both html/template.FuncMap and text/template.FuncMap
+func (e *Environment) Funcs(funcMap map[string]any) *Environment {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
@@ -87,44 +127,326 @@ func (e *Environment) Global(global ...string) *Environment {
 	return e
 }
 
-func (e *Environment) NewHTMLTemplate(name string) *template.Template {
-	return template.New(name).Delims(e.left, e.right).Funcs(e.funcMap)
+// CacheLimit bounds the template cache to maxEntries templates and to maxBytes of template
+// sources, evicting the least recently used templates first. Zero disables a limit.
+func (e *Environment) CacheLimit(maxEntries int, maxBytes int64) *Environment {
+	e.maxEntries.Store(int64(maxEntries))
+	e.maxBytes.Store(maxBytes)
+
+	return e
+}
+
+// CacheTTL evicts cached templates ttl after they were parsed. Zero disables expiry.
+func (e *Environment) CacheTTL(ttl time.Duration) *Environment {
+	e.ttl.Store(int64(ttl))
+
+	return e
+}
+
+// Stats returns the template cache statistics
+func (e *Environment) Stats() Stats {
+	c := e.templates.Load()
+
+	return Stats{
+		Entries:      int(c.count.Load()),
+		Bytes:        c.bytes.Load(),
+		Hits:         e.stats.hits.Load(),
+		Misses:       e.stats.misses.Load(),
+		Reparses:     e.stats.reparses.Load(),
+		Evictions:    e.stats.evictions.Load(),
+		Parses:       e.stats.parses.Load(),
+		ParseTime:    time.Duration(e.stats.parseTime.Load()),
+		MaxParseTime: time.Duration(e.stats.maxParseTime.Load()),
+	}
+}
+
+func (e *Environment) NewHTMLTemplate(name string) *htmltemplate.Template {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.newHTMLTemplate(name)
+}
+
+func (e *Environment) NewTextTemplate(name string) *texttemplate.Template {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.newTextTemplate(name)
+}
+
+// NewTemplate returns a new template of the environment's engine
+func (e *Environment) NewTemplate(name string) Template {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.newTemplate(name)
 }
 
 func (e *Environment) NewTemplateWrapper(name string) *TemplateWrapper {
-	return NewTemplateWrapper(
-		e.NewHTMLTemplate(name),
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	w := newTemplateWrapper(
+		e.newTemplate(name),
 		e.loader,
 		e.handlers,
-		e.reExtends,
-		e.reTemplate,
+		e.left,
+		e.right,
 		e.global...,
 	)
+	w.hash = e.hash.Load().(string)
+
+	return w
 }
 
+// Load returns the parsed template. Cached fresh templates are returned without locking,
+// concurrent loads of the same stale or missing template are coalesced into a single parse.
+// When the loader is Immutable, or a Notifier currently notifying about all changes, freshness
+// is not checked at all: cached templates are dropped as their dependencies change.
+//
+// Layouts named by extends expressions are not known without data, use Resolve for templates
+// which choose their layout at render time.
 func (e *Environment) Load(ctx context.Context, name string) (*TemplateWrapper, error) {
-	e.mu.Lock()
-	defer e.mu.Unlock()
+	return e.load(ctx, name, nil)
+}
 
-	var wrapper *TemplateWrapper
+// Resolve returns the template to render data with. Templates whose extends actions name layouts
+// with expressions, like {{extends .Layout "layout.html"}}, are parsed and cached once per
+// combination of existing layouts the expressions evaluate to against data. All names of
+// templates which do not exist share one variant.
+func (e *Environment) Resolve(ctx context.Context, name string, data any) (*TemplateWrapper, error) {
+	w, err := e.Load(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var values map[layoutExpr]string
+	for {
+		exprs := w.Snapshot().exprs
+
+		if len(exprs) == 0 || (values != nil && !slices.ContainsFunc(exprs, func(x layoutExpr) bool {
+			_, ok := values[x]
+			return !ok
+		})) {
+			return w, nil
+		}
+
+		if values == nil {
+			values = make(map[layoutExpr]string, len(exprs))
+		}
+		for _, x := range exprs {
+			if _, ok := values[x]; ok {
+				continue
+			}
+			if values[x], err = e.layout(ctx, w, x, data); err != nil {
+				return nil, &ExecuteError{Name: name, Err: fmt.Errorf("evaluate extends %s: %w", x.expr, err)}
+			}
+		}
+
+		if w, err = e.load(ctx, name, values); err != nil {
+			return nil, err
+		}
+	}
+}
 
-	key := e.key(name)
+func (e *Environment) load(ctx context.Context, name string, values map[layoutExpr]string) (*TemplateWrapper, error) {
+	name, err := Name(name)
+	if err != nil {
+		return nil, err
+	}
 
-	v, ok := e.templates.Load(key)
-	if ok {
-		wrapper = v.(*TemplateWrapper)
-	} else {
-		wrapper = e.NewTemplateWrapper(name)
+	key := e.key(name, values)
+	templates := e.templates.Load()
+	now := time.Now().UnixNano()
+	ttl := time.Duration(e.ttl.Load())
 
-		e.templates.Store(key, wrapper)
+	if entry, ok := templates.load(key); ok && !e.debug.Load() && !templates.expired(entry, now, ttl) &&
+		(e.immutable || e.notifying() || entry.wrapper.IsFresh(ctx)) {
+		if e.maxEntries.Load() > 0 || e.maxBytes.Load() > 0 {
+			templates.touch(entry)
+		}
+		e.stats.hits.Add(1)
+		return entry.wrapper, nil
 	}
+	e.stats.misses.Add(1)
+
+	v, err := e.group.Do(key, func() (any, error) {
+		var wrapper *TemplateWrapper
+		if entry, ok := templates.load(key); !ok {
+			wrapper = e.NewTemplateWrapper(name)
+			wrapper.values = values
+		} else if templates.expired(entry, now, ttl) {
+			wrapper = e.NewTemplateWrapper(name)
+			wrapper.values = values
+			if templates.delete(entry) {
+				e.stats.evictions.Add(1)
+			}
+		} else {
+			wrapper = entry.wrapper
+			e.stats.reparses.Add(1)
+		}
 
-	if !ok || e.debug || !wrapper.IsFresh(ctx) {
-		if err := wrapper.Parse(ctx); err != nil {
+		epoch := templates.epoch.Load()
+		start := time.Now()
+		err := wrapper.Parse(context.WithoutCancel(ctx))
+		e.stats.parsed(time.Since(start))
+		if err != nil {
 			return nil, err
 		}
+
+		if templates.store(key, wrapper, now, epoch) {
+			e.stats.evictions.Add(templates.prune(int(e.maxEntries.Load()), e.maxBytes.Load(), ttl, now, key))
+		}
+		return wrapper, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*TemplateWrapper), nil
+}
+
+// Warmup loads every template of the loader matching any of patterns so that they are cached
+// and their errors surface before the first render. Without patterns, the templates no other
+// template extends or includes are loaded, as layouts and partials may use templates defined
+// by their children. Templates are parsed concurrently and all failures are returned joined,
+// in the order of template names. The loader must be a Lister.
+func (e *Environment) Warmup(ctx context.Context, patterns ...string) error {
+	lister, ok := e.loader.(Lister)
+	if !ok {
+		return fmt.Errorf("warmup: loader %s is not a Lister", internal.TypeName(e.loader))
+	}
+
+	names, err := e.entries(ctx, lister, patterns...)
+	if err != nil {
+		return err
+	}
+
+	errs := make([]error, len(names))
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+
+	var wg sync.WaitGroup
+loop:
+	for i, name := range names {
+		if errs[i] = ctx.Err(); errs[i] != nil {
+			break
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+			break loop
+		}
+
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			_, errs[i] = e.Load(ctx, name)
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// Render resolves the template and executes it with data
+func (e *Environment) Render(ctx context.Context, wr io.Writer, name string, data any) error {
+	w, err := e.Resolve(ctx, name, data)
+	if err != nil {
+		return err
+	}
+	return w.Execute(wr, data)
+}
+
+// RenderBlock resolves the template and executes only one of its blocks with data
+func (e *Environment) RenderBlock(ctx context.Context, wr io.Writer, name, block string, data any) error {
+	w, err := e.Resolve(ctx, name, data)
+	if err != nil {
+		return err
 	}
-	return wrapper, nil
+	return w.ExecuteBlock(wr, block, data)
+}
+
+// Invalidate drops the cached templates named by names, together with every cached template
+// which extends or includes any of them. Parses in progress are not cached if they depend on
+// any of names.
+func (e *Environment) Invalidate(names ...string) {
+	names = normalizeNames(names)
+	templates := e.templates.Load()
+	templates.invalidate(names...)
+	templates.entries.Range(func(_, value any) bool {
+		if entry := value.(*cacheEntry); entry.wrapper.dependsOn(names...) {
+			templates.delete(entry)
+		}
+		return true
+	})
+}
+
+// Dependents returns the sorted names of the cached templates which extend or include any of
+// names, directly or through other templates. Only cached templates are considered, call Warmup
+// first to take all entry templates of the loader into account.
+func (e *Environment) Dependents(names ...string) []string {
+	names = normalizeNames(names)
+	set := make(map[string]struct{})
+	e.templates.Load().entries.Range(func(_, value any) bool {
+		w := value.(*cacheEntry).wrapper
+		if s := w.Snapshot(); s != nil && !slices.Contains(names, w.name) {
+			for _, name := range names {
+				if _, ok := s.names[name]; ok {
+					set[w.name] = struct{}{}
+					break
+				}
+			}
+		}
+		return true
+	})
+	return sortedNames(set)
+}
+
+// InvalidateAll drops all cached templates
+func (e *Environment) InvalidateAll() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.templates.Store(new(cache))
+}
+
+// normalizeNames normalises names like Load does, invalid names are kept as they are
+func normalizeNames(names []string) []string {
+	normalized := make([]string, len(names))
+	for i, name := range names {
+		if n, err := Name(name); err == nil {
+			normalized[i] = n
+		} else {
+			normalized[i] = name
+		}
+	}
+	return normalized
+}
+
+// Close unsubscribes the environment from a Notifier loader, so that a loader shared with
+// other environments does not keep it alive. Templates are checked for freshness afterwards.
+func (e *Environment) Close() {
+	if e.unsubscribe != nil && e.closed.CompareAndSwap(false, true) {
+		e.unsubscribe()
+	}
+}
+
+func (e *Environment) notifying() bool {
+	return e.notifier != nil && !e.closed.Load() && e.notifier.Notifying()
+}
+
+// notify is subscribed to a Notifier loader, a notification without names drops all templates
+func (e *Environment) notify(names ...string) {
+	if len(names) == 0 {
+		e.InvalidateAll()
+		return
+	}
+	e.Invalidate(names...)
 }
 
 func (e *Environment) updateHash() {
@@ -132,18 +454,97 @@ func (e *Environment) updateHash() {
 
 	buf.WriteString(e.left)
 	buf.WriteString(e.right)
-	buf.WriteString(strconv.FormatBool(e.debug))
+	buf.WriteString(strconv.FormatBool(e.debug.Load()))
 	for _, s := range e.global {
 		buf.WriteString(s)
 	}
+	names := make([]string, 0, len(e.funcMap))
 	for name := range e.funcMap {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
 		buf.WriteString(name)
 	}
 
 	e.hash.Store(internal.Hash(buf.Bytes()))
-	e.templates = new(sync.Map)
+	e.templates.Store(new(cache))
+}
+
+func (e *Environment) newHTMLTemplate(name string) *htmltemplate.Template {
+	return htmltemplate.New(name).Delims(e.left, e.right).Funcs(e.funcMap)
+}
+
+func (e *Environment) newTextTemplate(name string) *texttemplate.Template {
+	return texttemplate.New(name).Delims(e.left, e.right).Funcs(e.funcMap)
+}
+
+func (e *Environment) newTemplate(name string) Template {
+	if e.text {
+		return WrapText(e.newTextTemplate(name))
+	}
+	return WrapHTML(e.newHTMLTemplate(name))
+}
+
+// layout evaluates an extends expression of a template of w against data to the name of the
+// layout it chooses, to missingLayout if that layout does not exist or to "" if it names none
+func (e *Environment) layout(ctx context.Context, w *TemplateWrapper, x layoutExpr, data any) (string, error) {
+	name, err := e.eval(x.expr, data)
+	if err != nil || name == "" {
+		return "", err
+	}
+
+	if name, err = w.resolver().name(x.from, internal.Directive{Name: name}); err != nil {
+		return "", err
+	}
+
+	if ok, _ := e.loader.Exists(ctx, name); !ok {
+		return missingLayout, nil
+	}
+	return name, nil
 }
 
-func (e *Environment) key(name string) string {
-	return internal.Hash(internal.Bytes(fmt.Sprintf("%s:%s", name, e.hash.Load())))
+// eval evaluates an extends expression against data, expressions are parsed once per cache
+func (e *Environment) eval(expr string, data any) (string, error) {
+	exprs := &e.templates.Load().exprs
+
+	t, ok := exprs.Load(expr)
+	if !ok {
+		e.mu.RLock()
+		tmpl, err := e.newTextTemplate("extends").Parse(e.left + expr + e.right)
+		e.mu.RUnlock()
+		if err != nil {
+			return "", err
+		}
+		t, _ = exprs.LoadOrStore(expr, tmpl)
+	}
+
+	var b strings.Builder
+	if err := t.(*texttemplate.Template).Execute(&b, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+func (e *Environment) key(name string, values map[layoutExpr]string) string {
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte(':')
+	b.WriteString(e.hash.Load().(string))
+
+	exprs := make([]layoutExpr, 0, len(values))
+	for x := range values {
+		exprs = append(exprs, x)
+	}
+	slices.SortFunc(exprs, compareLayoutExprs)
+	for _, x := range exprs {
+		b.WriteByte(0)
+		b.WriteString(x.from)
+		b.WriteByte(0)
+		b.WriteString(x.expr)
+		b.WriteByte(0)
+		b.WriteString(values[x])
+	}
+
+	return internal.Hash(internal.Bytes(b.String()))
 }
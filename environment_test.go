@@ -1,9 +1,18 @@
 package et_test
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"html/template"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/stretchr/testify/assert"
@@ -64,10 +73,777 @@ func TestEnvironment_Load(t *testing.T) {
 			} else {
 				assert.NotNil(t, w)
 				assert.Nil(t, err)
-				if assert.NotNil(t, w.HTML) {
-					assert.Equal(t, s.view, w.HTML.Name())
+				if assert.NotNil(t, w.HTML()) {
+					assert.Equal(t, s.view, w.HTML().Name())
 				}
 			}
 		}
 	}
 }
+
+func TestTextEnvironment_Load(t *testing.T) {
+	env := et.NewTextEnvironment(et.NewMemoryLoader(map[string][]byte{
+		"layout.txt": []byte(`Hello, {{block "name" .}}{{end}}!`),
+		"mail.txt":   []byte(`{{extends "layout.txt"}}{{define "name"}}<{{.}}>{{end}}`),
+	}))
+
+	w, err := env.Load(context.TODO(), "mail.txt")
+	if assert.NoError(t, err) && assert.NotNil(t, w.Text()) {
+		assert.Nil(t, w.HTML())
+
+		var out bytes.Buffer
+		if err = w.Text().ExecuteTemplate(&out, "mail.txt", "John & Jane"); assert.NoError(t, err) {
+			assert.Equal(t, "Hello, <John & Jane>!", out.String())
+		}
+	}
+}
+
+func TestEnvironment_Render(t *testing.T) {
+	env := et.NewEnvironment(et.NewMemoryLoader(map[string][]byte{
+		"layout.html":      []byte(`<body>{{block "content" .}}{{end}}</body>`),
+		"view.html":        []byte(`{{extends "layout.html"}}{{define "content"}}<p>{{.}}</p>{{end}}`),
+		"broken.html":      []byte(`{{if}}`),
+		"missing.html":     []byte(`{{extends "no-layout.html"}}`),
+		"exec-broken.html": []byte(`{{.Field}}`),
+	}))
+
+	scenarios := []struct {
+		view     string
+		block    string
+		expected string
+		target   any
+	}{
+		{
+			view:     "view.html",
+			expected: "<body><p>hello</p></body>",
+		},
+		{
+			view:     "view.html",
+			block:    "content",
+			expected: "<p>hello</p>",
+		},
+		{
+			view:   "no-view.html",
+			target: new(*et.LoadError),
+		},
+		{
+			view:   "missing.html",
+			target: new(*et.LoadError),
+		},
+		{
+			view:   "broken.html",
+			target: new(*et.ParseError),
+		},
+		{
+			view:   "exec-broken.html",
+			target: new(*et.ExecuteError),
+		},
+		{
+			view:   "view.html",
+			block:  "no-block",
+			target: new(*et.ExecuteError),
+		},
+	}
+
+	for _, s := range scenarios {
+		var (
+			out bytes.Buffer
+			err error
+		)
+
+		if s.block == "" {
+			err = env.Render(context.TODO(), &out, s.view, "hello")
+		} else {
+			err = env.RenderBlock(context.TODO(), &out, s.view, s.block, "hello")
+		}
+
+		if s.target != nil {
+			assert.ErrorAs(t, err, s.target)
+		} else if assert.NoError(t, err) {
+			assert.Equal(t, s.expected, out.String())
+		}
+	}
+}
+
+func BenchmarkEnvironment_Load(b *testing.B) {
+	views := map[string][]byte{
+		"layout.html": []byte(`<body>{{block "content" .}}{{end}}</body>`),
+	}
+	names := make([]string, 16)
+	for i := range names {
+		names[i] = fmt.Sprintf("view%d.html", i)
+		views[names[i]] = []byte(`{{extends "layout.html"}}{{define "content"}}<p>{{.}}</p>{{end}}`)
+	}
+
+	env := et.NewEnvironment(et.NewMemoryLoader(views))
+
+	benchmarkLoad(b, env, names, 1)
+}
+
+func BenchmarkEnvironment_LoadFS(b *testing.B) {
+	loader := et.NewFileSystemLoader(os.DirFS("./tests"))
+	if err := loader.SetPaths("test_ns", "main", "base"); err != nil {
+		b.Fatal(err)
+	}
+	env := et.NewEnvironment(loader)
+	names := []string{"@test_ns/views/home.html", "@test_ns/layout.html"}
+
+	benchmarkLoad(b, env, names, 1)
+}
+
+// slowLoader takes as long to read a template as a network or a cold disk would
+type slowLoader struct {
+	et.Loader
+}
+
+func (l slowLoader) Get(ctx context.Context, name string) (*et.Source, error) {
+	time.Sleep(200 * time.Microsecond)
+	return l.Loader.Get(ctx, name)
+}
+
+// BenchmarkEnvironment_LoadParse parses on every load, where the global lock made concurrent
+// parses of different templates wait for each other. Reading templates waits rather than
+// computes, so it runs with more goroutines than CPUs.
+func BenchmarkEnvironment_LoadParse(b *testing.B) {
+	views := map[string][]byte{
+		"layout.html": []byte(`<body>{{block "content" .}}{{end}}</body>`),
+	}
+	names := make([]string, 16)
+	for i := range names {
+		names[i] = fmt.Sprintf("view%d.html", i)
+		views[names[i]] = []byte(`{{extends "layout.html"}}{{define "content"}}<p>{{.}}</p>{{end}}`)
+	}
+
+	env := et.NewEnvironment(slowLoader{et.NewMemoryLoader(views)}).Debug(true)
+
+	benchmarkLoad(b, env, names, 8)
+}
+
+// benchmarkLoad loads names in parallel, and behind one mutex as a baseline of how loads
+// scaled when the environment serialised them. Concurrent loads take turns through names,
+// so that they load different templates rather than share the parse of one.
+func benchmarkLoad(b *testing.B, env *et.Environment, names []string, parallelism int) {
+	var mu sync.Mutex
+	for _, serialized := range []bool{false, true} {
+		b.Run(map[bool]string{false: "parallel", true: "serialized"}[serialized], func(b *testing.B) {
+			var next atomic.Int64
+			b.ReportAllocs()
+			b.SetParallelism(parallelism)
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					name := names[next.Add(1)%int64(len(names))]
+					if serialized {
+						mu.Lock()
+					}
+					_, err := env.Load(context.TODO(), name)
+					if serialized {
+						mu.Unlock()
+					}
+					if err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
+
+type countLoader struct {
+	et.Loader
+	gets atomic.Int32
+}
+
+func (l *countLoader) Get(ctx context.Context, name string) (*et.Source, error) {
+	l.gets.Add(1)
+	time.Sleep(10 * time.Millisecond)
+	return l.Loader.Get(ctx, name)
+}
+
+func TestEnvironment_LoadConcurrent(t *testing.T) {
+	loader := &countLoader{Loader: et.NewMemoryLoader(map[string][]byte{
+		"layout.html": []byte(`<body>{{block "content" .}}{{end}}</body>`),
+		"view.html":   []byte(`{{extends "layout.html"}}{{define "content"}}{{.}}{{end}}`),
+	})}
+	env := et.NewEnvironment(loader)
+
+	var wg sync.WaitGroup
+	for range 32 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var out bytes.Buffer
+			if assert.NoError(t, env.Render(context.TODO(), &out, "view.html", "ok")) {
+				assert.Equal(t, "<body>ok</body>", out.String())
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(2), loader.gets.Load())
+}
+
+func TestEnvironment_LoadCycle(t *testing.T) {
+	env := et.NewEnvironment(et.NewMemoryLoader(map[string][]byte{
+		"@main/a.html":       []byte(`{{extends "b.html"}}`),
+		"@main/b.html":       []byte(`{{extends "a.html"}}`),
+		"@main/self.html":    []byte(`{{template "self.html"}}`),
+		"@main/partial.html": []byte(`<p>partial</p>`),
+		"@main/include.html": []byte(`{{template "self.html"}}`),
+		"@main/left.html":    []byte(`{{template "partial.html"}}`),
+		"@main/right.html":   []byte(`{{template "partial.html"}}`),
+		"@main/diamond.html": []byte(`{{template "left.html"}}{{template "right.html"}}`),
+		"@main/tree.html":    []byte(`{{define "tree"}}{{if .}}{{len .}}{{template "tree" slice . 1}}{{end}}{{end}}{{template "tree" .}}`),
+	}))
+
+	scenarios := []struct {
+		view     string
+		chain    []string
+		expected string
+	}{
+		{
+			view:  "@main/a.html",
+			chain: []string{"@main/a.html", "@main/b.html", "@main/a.html"},
+		},
+		{
+			view:  "@main/include.html",
+			chain: []string{"@main/include.html", "@main/self.html", "@main/self.html"},
+		},
+		{
+			view:     "@main/diamond.html",
+			expected: "<p>partial</p><p>partial</p>",
+		},
+		{
+			view:     "@main/tree.html",
+			expected: "321",
+		},
+	}
+
+	for _, s := range scenarios {
+		var out bytes.Buffer
+		err := env.Render(context.TODO(), &out, s.view, []int{1, 2, 3})
+
+		if s.chain != nil {
+			var cycleErr *et.CycleError
+			if assert.ErrorAs(t, err, &cycleErr) {
+				assert.Equal(t, s.chain, cycleErr.Chain)
+				assert.Contains(t, err.Error(), strings.Join(s.chain, " -> "))
+			}
+		} else if assert.NoError(t, err) {
+			assert.Equal(t, s.expected, out.String())
+		}
+	}
+}
+
+func TestEnvironment_LoadDirectives(t *testing.T) {
+	env := et.NewEnvironment(et.NewMemoryLoader(map[string][]byte{
+		"@main/global.html": []byte(`[[define "title_h1"]]<h1>[[.]]</h1>[[end]]`),
+		"@main/layout.html": []byte(`[[define "nav"]]<nav/>[[end]]<body>[[block "content" .]][[end]]</body>`),
+		"@main/card.html":   []byte(`<card/>`),
+		"@main/view.html": []byte(`[[/* [[template "missing.html"]] */]][[extends "layout.html"]]
+[[define "content"]][[template "title_h1" "Title"]][[template "nav"]][[template "card.html"]][[template "card.html"]]{{template "raw.html"}}[[end]]`),
+	})).Delims("[[", "]]").Global("@main/global.html")
+
+	var out bytes.Buffer
+	if err := env.Render(context.TODO(), &out, "@main/view.html", nil); assert.NoError(t, err) {
+		assert.Equal(t, `<body><h1>Title</h1><nav/><card/><card/>{{template "raw.html"}}</body>`, out.String())
+	}
+}
+
+type failData struct{}
+
+func (failData) Fail() (string, error) {
+	return "", errors.New("fail")
+}
+
+func TestEnvironment_RenderTemplateError(t *testing.T) {
+	env := et.NewEnvironment(et.NewMemoryLoader(map[string][]byte{
+		"@main/layout.html":  []byte("<body>\n{{block \"content\" .}}{{end}}\n{{template \"partial.html\" .}}\n</body>"),
+		"@main/partial.html": []byte("<p>\n  {{.Fail}}\n</p>"),
+		"@main/view.html":    []byte("{{extends \"layout.html\"}}\n{{define \"content\"}}\n<p>{{template \"title.html\"}}{{.Fail}}</p>{{end}}"),
+		"@main/title.html":   []byte("<h1>title</h1>"),
+		"@main/broken.html":  []byte("{{extends \"layout.html\"}}\n{{define \"content\"}}\n  {{if}}\n{{end}}"),
+		"@main/layout2.html": []byte("<body>\n  {{.Fail}}{{block \"content\" .}}{{end}}</body>"),
+		"@main/view2.html":   []byte(`{{extends "layout2.html"}}{{define "content"}}{{end}}`),
+		"@main/view3.html":   []byte(`{{extends "layout.html"}}{{define "content"}}{{end}}`),
+		"@main/nofunc.html":  []byte("{{extends \"layout.html\"}}\n\n{{define \"content\"}}{{nofunc}}{{end}}"),
+	}))
+
+	scenarios := []struct {
+		view     string
+		name     string
+		line     int
+		col      int
+		chain    []string
+		contains string
+	}{
+		{
+			view:  "@main/broken.html",
+			name:  "@main/broken.html",
+			line:  3,
+			chain: []string{"@main/broken.html"},
+		},
+		{
+			view:     "@main/nofunc.html",
+			name:     "@main/nofunc.html",
+			line:     3,
+			chain:    []string{"@main/nofunc.html"},
+			contains: `function "nofunc" not defined`,
+		},
+		{
+			view:     "@main/view.html",
+			name:     "@main/view.html",
+			line:     3,
+			col:      strings.Index(`<p>{{template "title.html"}}{{.Fail}}</p>`, ".Fail") + 1,
+			chain:    []string{"@main/view.html"},
+			contains: `executing "content"`,
+		},
+		{
+			view:  "@main/view2.html",
+			name:  "@main/layout2.html",
+			line:  2,
+			col:   5,
+			chain: []string{"@main/view2.html", "@main/layout2.html"},
+		},
+		{
+			view:     "@main/view3.html",
+			name:     "@main/partial.html",
+			line:     2,
+			col:      5,
+			chain:    []string{"@main/view3.html", "@main/layout.html", "@main/partial.html"},
+			contains: "(chain: @main/view3.html -> @main/layout.html -> @main/partial.html)",
+		},
+	}
+
+	for _, s := range scenarios {
+		err := env.Render(context.TODO(), io.Discard, s.view, failData{})
+
+		var templateErr *et.TemplateError
+		if assert.ErrorAs(t, err, &templateErr) {
+			assert.Equal(t, s.name, templateErr.Name)
+			assert.Equal(t, s.line, templateErr.Line)
+			assert.Equal(t, s.col, templateErr.Col)
+			assert.Equal(t, s.chain, templateErr.Chain)
+			assert.Contains(t, templateErr.Error(), s.contains)
+		}
+	}
+}
+
+func TestEnvironment_LoadSubSecond(t *testing.T) {
+	fsys := fstest.MapFS{
+		"base/view.html": &fstest.MapFile{Data: []byte("view"), ModTime: time.Now().Add(-time.Hour)},
+	}
+	loader := et.NewFileSystemLoader(fsys)
+	_ = loader.BaseAppend("base")
+	env := et.NewEnvironment(loader)
+
+	w, err := env.Load(context.TODO(), "view.html")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// edited within the same second the template was parsed in
+	fsys["base/view.html"] = &fstest.MapFile{Data: []byte("edit"), ModTime: time.Unix(0, w.Snapshot().UnixNano()).Add(time.Millisecond)}
+
+	var out bytes.Buffer
+	if assert.NoError(t, env.Render(context.TODO(), &out, "view.html", nil)) {
+		assert.Equal(t, "edit", out.String())
+	}
+}
+
+func TestEnvironment_Warmup(t *testing.T) {
+	loader := et.NewMemoryLoader(map[string][]byte{
+		"layout.html":        []byte(`<body>{{block "content" .}}{{end}}</body>`),
+		"views/home.html":    []byte(`{{extends "layout.html"}}{{define "content"}}home{{end}}`),
+		"views/broken.html":  []byte(`{{extends "layout.html"}}{{define "content"}}{{if}}{{end}}`),
+		"views/missing.html": []byte(`{{extends "missing.html"}}`),
+		"mail/welcome.txt":   []byte(`{{if}}`),
+	})
+	env := et.NewEnvironment(loader)
+
+	err := env.Warmup(context.TODO(), "views/**")
+
+	var parseErr *et.ParseError
+	var loadErr *et.LoadError
+	if assert.Error(t, err) && assert.ErrorAs(t, err, &parseErr) && assert.ErrorAs(t, err, &loadErr) {
+		assert.Equal(t, "views/broken.html", parseErr.Name)
+		assert.Equal(t, "views/missing.html", loadErr.Name)
+		assert.NotContains(t, err.Error(), "welcome.txt")
+	}
+
+	w, err := env.Load(context.TODO(), "views/home.html")
+	if assert.NoError(t, err) {
+		assert.NotNil(t, w.Snapshot())
+	}
+
+	assert.NoError(t, env.Warmup(context.TODO(), "layout.html", "views/home.html"))
+}
+
+func TestEnvironment_WarmupEntries(t *testing.T) {
+	env := et.NewEnvironment(et.NewMemoryLoader(map[string][]byte{
+		"@main/globals/funcs.html": []byte(`{{define "upper"}}{{.}}{{end}}`),
+		"@main/layout.html":        []byte(`<body>{{template "partials/head.html" .}}{{block "content" .}}{{end}}</body>`),
+		"@main/partials/head.html": []byte(`<title>{{template "title" .}}</title>`),
+		"@main/pages/home.html":    []byte(`{{extends "../layout.html"}}{{define "title"}}home{{end}}{{define "content"}}home{{end}}`),
+		"@main/pages/sitemap.xml":  []byte(`<urlset/>`),
+		"@main/pages/broken.html":  []byte(`{{if}}`),
+	})).Global("globals/funcs.html")
+
+	// layouts, partials and globals use templates defined by their children and are not entries
+	err := env.Warmup(context.TODO())
+
+	var parseErr *et.ParseError
+	if assert.ErrorAs(t, err, &parseErr) {
+		assert.Equal(t, "@main/pages/broken.html", parseErr.Name)
+		assert.NotContains(t, err.Error(), "head.html")
+	}
+	assert.Equal(t, 2, env.Stats().Entries)
+}
+
+func TestEnvironment_WarmupDynamicLayout(t *testing.T) {
+	env := et.NewEnvironment(et.NewMemoryLoader(map[string][]byte{
+		"@main/layout.html": []byte(`<body>{{block "content" .}}{{end}}</body>`),
+		"@main/amp.html":    []byte(`<amp>{{template "content" .}}</amp>`),
+		"@main/view.html":   []byte(`{{extends .Layout "@main/layout.html"}}{{define "content"}}view{{end}}`),
+	}))
+
+	// amp.html is only reached through the extends expression of view.html
+	if assert.NoError(t, env.Warmup(context.TODO())) {
+		assert.Equal(t, 1, env.Stats().Entries)
+	}
+
+	var out bytes.Buffer
+	if assert.NoError(t, env.Render(context.TODO(), &out, "@main/view.html", map[string]any{"Layout": "@main/amp.html"})) {
+		assert.Equal(t, "<amp>view</amp>", out.String())
+	}
+}
+
+func TestEnvironment_WarmupNotLister(t *testing.T) {
+	env := et.NewEnvironment(&countLoader{Loader: et.NewMemoryLoader(nil)})
+
+	assert.Error(t, env.Warmup(context.TODO()))
+}
+
+func TestEnvironment_WarmupCanceled(t *testing.T) {
+	env := et.NewEnvironment(et.NewMemoryLoader(map[string][]byte{"view.html": nil}))
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	assert.ErrorIs(t, env.Warmup(ctx), context.Canceled)
+}
+
+func TestEnvironment_Invalidate(t *testing.T) {
+	env := et.NewEnvironment(et.NewMemoryLoader(map[string][]byte{
+		"layout.html":  []byte(`<body>{{block "content" .}}{{end}}</body>`),
+		"menu.html":    []byte(`<nav></nav>`),
+		"home.html":    []byte(`{{extends "layout.html"}}{{define "content"}}{{template "menu.html"}}{{end}}`),
+		"about.html":   []byte(`{{extends "layout.html"}}{{define "content"}}about{{end}}`),
+		"contact.html": []byte(`contact`),
+	}))
+
+	load := func(name string) *et.TemplateWrapper {
+		w, err := env.Load(context.TODO(), name)
+		assert.NoError(t, err)
+		return w
+	}
+
+	home, about, contact := load("home.html"), load("about.html"), load("contact.html")
+
+	env.Invalidate("menu.html")
+
+	assert.NotSame(t, home, load("home.html"))
+	assert.Same(t, about, load("about.html"))
+	assert.Same(t, contact, load("contact.html"))
+
+	home = load("home.html")
+	env.Invalidate("layout.html")
+
+	assert.NotSame(t, home, load("home.html"))
+	assert.NotSame(t, about, load("about.html"))
+	assert.Same(t, contact, load("contact.html"))
+
+	env.Invalidate("contact.html")
+
+	assert.NotSame(t, contact, load("contact.html"))
+
+	// names are normalised like by Load
+	home = load("home.html")
+	assert.Equal(t, []string{"home.html"}, env.Dependents("./menu.html"))
+
+	env.Invalidate(".//menu.html")
+
+	assert.NotSame(t, home, load("home.html"))
+}
+
+// notifyLoader always notifies and calls hook after reading a template
+type notifyLoader struct {
+	*et.MemoryLoader
+	hook func(name string)
+}
+
+func (l *notifyLoader) Get(ctx context.Context, name string) (*et.Source, error) {
+	source, err := l.MemoryLoader.Get(ctx, name)
+	if l.hook != nil {
+		l.hook(name)
+	}
+	return source, err
+}
+
+func (l *notifyLoader) Notify(func(names ...string)) func() {
+	return func() {}
+}
+
+func (l *notifyLoader) Notifying() bool {
+	return true
+}
+
+func TestEnvironment_InvalidateDuringParse(t *testing.T) {
+	loader := &notifyLoader{MemoryLoader: et.NewMemoryLoader(map[string][]byte{
+		"layout.html": []byte(`<body>{{block "content" .}}{{end}}</body>`),
+		"view.html":   []byte(`{{extends "layout.html"}}{{define "content"}}view{{end}}`),
+	})}
+	env := et.NewEnvironment(loader)
+
+	// the layout changes after the parse read it, before the parse is cached
+	loader.hook = func(name string) {
+		if name == "layout.html" {
+			loader.hook = nil
+			loader.Add("layout.html", []byte(`<main>{{block "content" .}}{{end}}</main>`))
+			env.Invalidate("layout.html")
+		}
+	}
+
+	assert.Equal(t, "<body>view</body>", render(env, "view.html"))
+	assert.Equal(t, 0, env.Stats().Entries)
+	assert.Equal(t, "<main>view</main>", render(env, "view.html"))
+}
+
+func TestEnvironment_InvalidateAll(t *testing.T) {
+	env := et.NewEnvironment(et.NewMemoryLoader(map[string][]byte{"view.html": []byte(`view`)}))
+
+	w, _ := env.Load(context.TODO(), "view.html")
+	env.InvalidateAll()
+
+	assert.Equal(t, 0, env.Stats().Entries)
+
+	w1, err := env.Load(context.TODO(), "view.html")
+	assert.NoError(t, err)
+	assert.NotSame(t, w, w1)
+}
+
+func TestEnvironment_Dependents(t *testing.T) {
+	env := et.NewEnvironment(et.NewMemoryLoader(map[string][]byte{
+		"@main/layout.html":        []byte(`<body>{{template "partials/nav.html"}}{{block "content" .}}{{end}}</body>`),
+		"@main/partials/nav.html":  []byte(`<nav>{{template "partials/link.html"}}</nav>`),
+		"@main/partials/link.html": []byte(`<a></a>`),
+		"@main/home.html":          []byte(`{{extends "layout.html"}}{{define "content"}}home{{end}}`),
+		"@main/about.html":         []byte(`{{extends "layout.html"}}{{define "content"}}about{{end}}`),
+		"@main/plain.html":         []byte(`{{template "partials/link.html"}}`),
+		"@main/other.html":         []byte(`other`),
+	}))
+
+	assert.Empty(t, env.Dependents("@main/partials/nav.html"))
+
+	assert.NoError(t, env.Warmup(context.TODO()))
+
+	// layouts and partials are not cached as entry templates by Warmup
+	assert.Equal(t, []string{"@main/about.html", "@main/home.html"},
+		env.Dependents("@main/partials/nav.html"))
+	assert.Equal(t, []string{"@main/about.html", "@main/home.html", "@main/plain.html"},
+		env.Dependents("@main/partials/link.html"))
+	assert.Equal(t, []string{"@main/about.html", "@main/home.html"},
+		env.Dependents("@main/layout.html"))
+	assert.Empty(t, env.Dependents("@main/other.html"))
+}
+
+func TestEnvironment_RenderParent(t *testing.T) {
+	env := et.NewEnvironment(et.NewMemoryLoader(map[string][]byte{
+		"base.html":   []byte(`<head>{{block "head" .}}<title>{{block "title" .}}Site{{end}}</title>{{end}}</head>`),
+		"layout.html": []byte(`{{extends "base.html"}}{{define "head"}}{{parent}}<link>{{end}}{{define "title"}}{{.}} - {{parent}}{{end}}`),
+		"page.html":   []byte(`{{extends "layout.html"}}{{define "head"}}<meta>{{- parent -}}<script></script>{{end}}`),
+		"title.html":  []byte(`{{extends "layout.html"}}{{define "title"}}[{{parent}}]{{end}}`),
+		"skip.html":   []byte(`{{extends "layout.html"}}{{define "other"}}{{end}}{{define "head"}}{{if .}}{{parent}}{{end}}{{end}}`),
+	}))
+
+	scenarios := []struct {
+		view     string
+		expected string
+	}{
+		{view: "layout.html", expected: `<head><title>Home - Site</title><link></head>`},
+		{view: "page.html", expected: `<head><meta><title>Home - Site</title><link><script></script></head>`},
+		{view: "title.html", expected: `<head><title>[Home - Site]</title><link></head>`},
+		{view: "skip.html", expected: `<head><title>Home - Site</title><link></head>`},
+	}
+
+	for _, s := range scenarios {
+		var out bytes.Buffer
+		if assert.NoError(t, env.Render(context.TODO(), &out, s.view, "Home"), s.view) {
+			assert.Equal(t, s.expected, out.String(), s.view)
+		}
+	}
+}
+
+func TestEnvironment_RenderParentErrors(t *testing.T) {
+	env := et.NewTextEnvironment(et.NewMemoryLoader(map[string][]byte{
+		"base.html":      []byte(`{{block "content" .}}base{{end}}`),
+		"undefined.html": []byte("{{extends \"base.html\"}}\n{{define \"other\"}}{{parent}}{{end}}"),
+		"top.html":       []byte(`{{parent}}`),
+	}))
+
+	_, err := env.Load(context.TODO(), "undefined.html")
+
+	var templateErr *et.TemplateError
+	if assert.ErrorAs(t, err, &templateErr) {
+		assert.Equal(t, "undefined.html", templateErr.Name)
+		assert.Equal(t, 2, templateErr.Line)
+		assert.Equal(t, 21, templateErr.Col)
+		assert.Equal(t, `no parent template defines "other"`, templateErr.Description)
+	}
+
+	_, err = env.Load(context.TODO(), "top.html")
+	assert.ErrorContains(t, err, "parent action outside of a define or block")
+}
+
+func TestEnvironment_RenderFallbackExtends(t *testing.T) {
+	loader := et.NewMemoryLoader(map[string][]byte{
+		"layout.html": []byte(`<body>{{block "content" .}}{{end}}</body>`),
+		"view.html":   []byte(`{{extends "mobile.html" "layout.html"}}{{define "content"}}view{{end}}`),
+		"none.html":   []byte(`{{extends "a.html" "b.html"}}`),
+	})
+	env := et.NewEnvironment(loader)
+
+	var out bytes.Buffer
+	if assert.NoError(t, env.Render(context.TODO(), &out, "view.html", nil)) {
+		assert.Equal(t, "<body>view</body>", out.String())
+	}
+
+	// the preferred layout appearing later makes the template stale
+	loader.Add("mobile.html", []byte(`<main>{{block "content" .}}{{end}}</main>`))
+
+	out.Reset()
+	if assert.NoError(t, env.Render(context.TODO(), &out, "view.html", nil)) {
+		assert.Equal(t, "<main>view</main>", out.String())
+	}
+
+	_, err := env.Load(context.TODO(), "none.html")
+	assert.ErrorContains(t, err, `none of the layouts "a.html", "b.html" extended by "none.html" exists`)
+}
+
+func TestEnvironment_RenderDynamicExtends(t *testing.T) {
+	env := et.NewEnvironment(et.NewMemoryLoader(map[string][]byte{
+		"@main/layout.html": []byte(`<body>{{block "content" .}}{{end}}</body>`),
+		"@main/print.html":  []byte(`<pre>{{block "content" .}}{{end}}</pre>`),
+		"@main/modal.html":  []byte(`{{extends .Shell}}{{define "content"}}<dialog>{{parent}}</dialog>{{end}}`),
+		"@main/view.html":   []byte(`{{extends .Layout "layout.html"}}{{define "content"}}{{.Text}}{{end}}`),
+		"@main/strict.html": []byte(`{{extends (printf "%s.html" .Layout)}}{{define "content"}}{{.Text}}{{end}}`),
+	})).Funcs(template.FuncMap{})
+
+	scenarios := []struct {
+		view     string
+		data     map[string]string
+		expected string
+		isError  bool
+	}{
+		{view: "@main/view.html", data: map[string]string{"Text": "a"}, expected: "<body>a</body>"},
+		{view: "@main/view.html", data: map[string]string{"Text": "b", "Layout": "print.html"}, expected: "<pre>b</pre>"},
+		{view: "@main/view.html", data: map[string]string{"Text": "c", "Layout": "@main/print.html"}, expected: "<pre>c</pre>"},
+		{view: "@main/view.html", data: map[string]string{"Text": "d", "Layout": "amp.html"}, expected: "<body>d</body>"},
+		{view: "@main/view.html", data: map[string]string{"Text": "e", "Layout": "modal.html", "Shell": "print.html"}, expected: "<pre>e</pre>"},
+		{view: "@main/modal.html", data: map[string]string{"Shell": "print.html"}, expected: "<pre><dialog></dialog></pre>"},
+		{view: "@main/strict.html", data: map[string]string{"Text": "f", "Layout": "print"}, expected: "<pre>f</pre>"},
+		{view: "@main/strict.html", data: map[string]string{"Text": "g", "Layout": "amp"}, isError: true},
+	}
+
+	for _, s := range scenarios {
+		var out bytes.Buffer
+		err := env.Render(context.TODO(), &out, s.view, s.data)
+
+		if s.isError {
+			assert.Error(t, err)
+		} else if assert.NoError(t, err, s.data) {
+			assert.Equal(t, s.expected, out.String(), s.data)
+		}
+	}
+
+	// a variant is cached per layout
+	a, _ := env.Resolve(context.TODO(), "@main/view.html", map[string]string{"Layout": "print.html"})
+	b, _ := env.Resolve(context.TODO(), "@main/view.html", map[string]string{"Layout": "print.html"})
+	c, _ := env.Resolve(context.TODO(), "@main/view.html", map[string]string{})
+	assert.Same(t, a, b)
+	assert.NotSame(t, a, c)
+
+	_, err := env.Resolve(context.TODO(), "@main/view.html", 42)
+	var executeErr *et.ExecuteError
+	assert.ErrorAs(t, err, &executeErr)
+}
+
+func TestEnvironment_RenderDynamicExtendsMissing(t *testing.T) {
+	env := et.NewEnvironment(et.NewMemoryLoader(map[string][]byte{
+		"layout.html": []byte(`<body>{{block "content" .}}{{end}}</body>`),
+		"print.html":  []byte(`<pre>{{block "content" .}}{{end}}</pre>`),
+		"view.html":   []byte(`{{extends .Layout "layout.html"}}{{define "content"}}view{{end}}`),
+		"strict.html": []byte(`{{extends .Layout}}{{define "content"}}strict{{end}}`),
+	}))
+
+	for i := range 1000 {
+		var out bytes.Buffer
+		if !assert.NoError(t, env.Render(context.TODO(), &out, "view.html", map[string]string{"Layout": fmt.Sprintf("missing-%d.html", i)})) {
+			return
+		}
+		assert.Equal(t, "<body>view</body>", out.String())
+	}
+
+	// the template as loaded and one variant for all layouts which do not exist
+	stats := env.Stats()
+	assert.Equal(t, 2, stats.Entries)
+	assert.Equal(t, uint64(2), stats.Parses)
+
+	// equivalent names of the same layout share a variant
+	a, _ := env.Resolve(context.TODO(), "view.html", map[string]string{"Layout": "print.html"})
+	b, _ := env.Resolve(context.TODO(), "view.html", map[string]string{"Layout": "./print.html"})
+	assert.Same(t, a, b)
+
+	_, err := env.Resolve(context.TODO(), "strict.html", map[string]string{"Layout": "missing.html"})
+	assert.ErrorContains(t, err, `none of the layouts .Layout extended by "strict.html" exists`)
+}
+
+func TestEnvironment_RenderRelative(t *testing.T) {
+	env := et.NewEnvironment(et.NewMemoryLoader(map[string][]byte{
+		"@main/layout.html":            []byte(`<body>{{block "content" .}}{{end}}</body>`),
+		"@main/pages/layout.html":      []byte(`{{extends "../layout.html"}}{{define "content"}}<main>{{block "page" .}}{{end}}</main>{{end}}`),
+		"@main/pages/card.html":        []byte(`<card>{{template "./parts/title.html"}}</card>`),
+		"@main/pages/parts/title.html": []byte(`<h1>{{template "../../partials/icon.html"}}</h1>`),
+		"@main/partials/icon.html":     []byte(`<i></i>`),
+		"@main/pages/home.html":        []byte(`{{extends "./layout.html"}}{{define "page"}}{{template "./card.html"}}{{template "card.html"}}{{end}}`),
+		"@main/pages/escape.html":      []byte("{{extends \"./layout.html\"}}\n{{define \"page\"}}{{template \"../../outside.html\"}}{{end}}"),
+		"views/home.html":              []byte(`{{template "./card.html"}}`),
+		"views/card.html":              []byte(`<card></card>`),
+		"views/escape.html":            []byte(`{{extends "../../layout.html"}}`),
+		"@main/card.html":              []byte(`<root-card></root-card>`),
+	}))
+
+	var out bytes.Buffer
+	if assert.NoError(t, env.Render(context.TODO(), &out, "@main/pages/home.html", nil)) {
+		assert.Equal(t, `<body><main><card><h1><i></i></h1></card><root-card></root-card></main></body>`, out.String())
+	}
+
+	out.Reset()
+	if assert.NoError(t, env.Render(context.TODO(), &out, "views/home.html", nil)) {
+		assert.Equal(t, `<card></card>`, out.String())
+	}
+
+	_, err := env.Load(context.TODO(), "@main/pages/escape.html")
+	var templateErr *et.TemplateError
+	if assert.ErrorAs(t, err, &templateErr) {
+		assert.Equal(t, "@main/pages/escape.html", templateErr.Name)
+		assert.Equal(t, 2, templateErr.Line)
+		assert.Equal(t, `invalid template name "../../outside.html": escapes the namespace root`, templateErr.Description)
+	}
+
+	assert.ErrorIs(t, err, et.ErrInvalidName)
+
+	_, err = env.Load(context.TODO(), "views/escape.html")
+	assert.ErrorContains(t, err, `invalid template name "../../layout.html": escapes the namespace root`)
+}
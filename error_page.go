@@ -0,0 +1,156 @@
+package et
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+const errorPageContext = 5
+
+var errorPageTemplate = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="UTF-8">
+<title>{{.Kind}}: {{.Name}}</title>
+<style>
+body{font-family:sans-serif;margin:2em;color:#222}
+h1{font-size:1.4em;color:#b00020}
+pre{background:#f6f6f6;padding:1em;overflow:auto}
+.error{white-space:pre-wrap}
+.source span{display:block}
+.source .highlight{background:#ffd7d7;font-weight:bold}
+dt{font-weight:bold}
+</style>
+</head>
+<body>
+<h1>{{.Kind}}{{with .Name}}: {{.}}{{end}}</h1>
+<pre class="error">{{.Error}}</pre>
+<dl>
+{{- with .File}}<dt>File</dt><dd>{{.}}</dd>{{end}}
+{{- if .Line}}<dt>Line</dt><dd>{{.Line}}{{if .Col}}:{{.Col}}{{end}}</dd>{{end}}
+<dt>Data</dt><dd><code>{{.DataType}}</code></dd>
+</dl>
+{{- with .Source}}
+<pre class="source">{{range .}}<span{{if .Highlight}} class="highlight"{{end}}>{{printf "%4d" .Number}} | {{.Text}}</span>{{end}}</pre>
+{{- end}}
+{{- with .Chain}}
+<h2>Chain</h2>
+<ol>{{range .}}<li>{{.}}</li>{{end}}</ol>
+{{- end}}
+</body>
+</html>
+`))
+
+type errorPageLine struct {
+	Number    int
+	Text      string
+	Highlight bool
+}
+
+type errorPageData struct {
+	Kind     string
+	Name     string
+	File     string
+	Line     int
+	Col      int
+	Error    string
+	DataType string
+	Source   []errorPageLine
+	Chain    []string
+}
+
+// ErrorPage renders template failures as HTTP responses. When the environment is in debug mode
+// the page shows the failing source with the offending line highlighted, the extends and include
+// chain and the type of the data passed in; otherwise only a bare 500 status is written.
+type ErrorPage struct {
+	env *Environment
+}
+
+func NewErrorPage(env *Environment) *ErrorPage {
+	return &ErrorPage{env: env}
+}
+
+// Handler returns an http.Handler which serves the error page for err
+func (p *ErrorPage) Handler(err error, data any) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.ServeError(w, r, err, data)
+	})
+}
+
+// ServeError writes the error page for err with a 500 status
+func (p *ErrorPage) ServeError(w http.ResponseWriter, r *http.Request, err error, data any) {
+	if !p.env.IsDebug() {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err1 := errorPageTemplate.Execute(&buf, p.data(r, err, data)); err1 != nil {
+		http.Error(w, errors.Join(err, err1).Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusInternalServerError)
+	_, _ = buf.WriteTo(w)
+}
+
+func (p *ErrorPage) data(r *http.Request, err error, data any) errorPageData {
+	d := errorPageData{
+		Kind:     "Template error",
+		Error:    err.Error(),
+		DataType: fmt.Sprintf("%T", data),
+	}
+
+	var (
+		loadErr     *LoadError
+		parseErr    *ParseError
+		executeErr  *ExecuteError
+		cycleErr    *CycleError
+		templateErr *TemplateError
+	)
+
+	switch {
+	case errors.As(err, &parseErr):
+		d.Kind, d.Name = "Parse error", parseErr.Name
+	case errors.As(err, &loadErr):
+		d.Kind, d.Name = "Load error", loadErr.Name
+	case errors.As(err, &executeErr):
+		d.Kind, d.Name = "Execute error", executeErr.Name
+	}
+
+	if errors.As(err, &cycleErr) {
+		d.Chain = cycleErr.Chain
+	}
+
+	if !errors.As(err, &templateErr) {
+		return d
+	}
+
+	d.Name = templateErr.Name
+	d.File = templateErr.File
+	d.Line = templateErr.Line
+	d.Col = templateErr.Col
+	d.Chain = templateErr.Chain
+
+	if source, err1 := p.env.loader.Get(r.Context(), templateErr.Name); err1 == nil {
+		lines := strings.Split(string(source.Code), "\n")
+
+		from := max(templateErr.Line-errorPageContext, 1)
+		to := min(templateErr.Line+errorPageContext, len(lines))
+		for i := from; i <= to; i++ {
+			d.Source = append(d.Source, errorPageLine{
+				Number:    i,
+				Text:      lines[i-1],
+				Highlight: i == templateErr.Line,
+			})
+		}
+	}
+
+	return d
+}
@@ -0,0 +1,64 @@
+package et_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	et "github.com/gowool/extends-template"
+)
+
+type pageData struct {
+	Title string
+}
+
+func TestErrorPage_ServeError(t *testing.T) {
+	loader := et.NewMemoryLoader(map[string][]byte{
+		"@main/layout.html": []byte("<body>\n{{block \"content\" .}}{{end}}\n</body>"),
+		"@main/view.html":   []byte("{{extends \"layout.html\"}}\n{{define \"content\"}}\n<h1>{{.Missing}}</h1>\n{{end}}"),
+	})
+
+	scenarios := []struct {
+		debug    bool
+		contains []string
+	}{
+		{
+			debug: true,
+			contains: []string{
+				"Execute error: @main/view.html",
+				`<span class="highlight">   3 | &lt;h1&gt;{{.Missing}}&lt;/h1&gt;</span>`,
+				"<code>et_test.pageData</code>",
+				"<li>@main/view.html</li>",
+			},
+		},
+		{
+			debug:    false,
+			contains: []string{http.StatusText(http.StatusInternalServerError)},
+		},
+	}
+
+	for _, s := range scenarios {
+		env := et.NewEnvironment(loader).Debug(s.debug)
+		data := pageData{Title: "title"}
+
+		err := env.Render(context.TODO(), io.Discard, "@main/view.html", data)
+		if !assert.Error(t, err) {
+			continue
+		}
+
+		rec := httptest.NewRecorder()
+		et.NewErrorPage(env).Handler(err, data).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		for _, c := range s.contains {
+			assert.Contains(t, rec.Body.String(), c)
+		}
+		if !s.debug {
+			assert.NotContains(t, rec.Body.String(), "Missing")
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package et
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var reLocation = regexp.MustCompile(`^(?:html/)?template: ?(.+?):(\d+)(?::(\d+))?: `)
+
+var (
+	ErrNotParsed   = errors.New("template is not parsed")
+	ErrInvalidName = errors.New("invalid template name")
+	ErrNotFound    = errors.New("template not found")
+)
+
+// notFoundError keeps the message of a loader error while matching ErrNotFound
+type notFoundError struct {
+	error
+}
+
+func (e notFoundError) Unwrap() []error {
+	return []error{e.error, ErrNotFound}
+}
+
+func notFound(err error) error {
+	return notFoundError{err}
+}
+
+// LoadError is returned when a template, one of its dependencies or a handler fails
+type LoadError struct {
+	Name string
+	Err  error
+}
+
+func (e *LoadError) Error() string {
+	return fmt.Sprintf("load template \"%s\": %s", e.Name, e.Err)
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+// ParseError is returned when the assembled template set cannot be parsed
+type ParseError struct {
+	Name string
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("parse template \"%s\": %s", e.Name, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ExecuteError is returned when a parsed template fails to execute
+type ExecuteError struct {
+	Name  string
+	Block string
+	Err   error
+}
+
+func (e *ExecuteError) Error() string {
+	if e.Block != "" {
+		return fmt.Sprintf("execute block \"%s\" of template \"%s\": %s", e.Block, e.Name, e.Err)
+	}
+	return fmt.Sprintf("execute template \"%s\": %s", e.Name, e.Err)
+}
+
+func (e *ExecuteError) Unwrap() error {
+	return e.Err
+}
+
+// CycleError is returned when templates extend or include each other in a loop
+type CycleError struct {
+	Chain []string
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("template cycle detected: %s", strings.Join(e.Chain, " -> "))
+}
+
+// TemplateError locates a parse or execution failure in the original template source
+// rather than in the synthetic template set assembled from the inheritance chain
+type TemplateError struct {
+	// Name is the loader name of the failing template
+	Name string
+
+	// File is the file of the failing template, if the loader knows it
+	File string
+
+	// Line is the 1-based line in the original source
+	Line int
+
+	// Col is the 1-based column in the original source or 0 if unknown
+	Col int
+
+	// Chain is the extends and include chain from the entry template to Name
+	Chain []string
+
+	// Description is the error message without its location
+	Description string
+
+	Err error
+}
+
+func (e *TemplateError) Error() string {
+	loc := e.Name
+	if e.File != "" {
+		loc = e.File
+	}
+
+	loc = fmt.Sprintf("%s:%d", loc, e.Line)
+	if e.Col > 0 {
+		loc = fmt.Sprintf("%s:%d", loc, e.Col)
+	}
+
+	if len(e.Chain) > 1 {
+		return fmt.Sprintf("template: %s: %s (chain: %s)", loc, e.Description, strings.Join(e.Chain, " -> "))
+	}
+	return fmt.Sprintf("template: %s: %s", loc, e.Description)
+}
+
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+// locate converts an error of text/template or html/template into a TemplateError
+// when its location refers to one of the parsed nodes
+func locate(nodes map[string]*Node, err error) error {
+	var templateErr *TemplateError
+	if errors.As(err, &templateErr) {
+		return err
+	}
+
+	msg := err.Error()
+
+	m := reLocation.FindStringSubmatch(msg)
+	if m == nil {
+		return err
+	}
+
+	n, ok := nodes[m[1]]
+	if !ok {
+		return err
+	}
+
+	line, _ := strconv.Atoi(m[2])
+	col := -1
+	if m[3] != "" {
+		col, _ = strconv.Atoi(m[3])
+	}
+
+	return newTemplateError(n, line, col, msg[len(m[0]):], err)
+}
+
+func newTemplateError(n *Node, line, col int, description string, err error) *TemplateError {
+	line, col = n.position(line, col)
+
+	if n.parsedAs != "" && n.parsedAs != n.name {
+		description = strings.ReplaceAll(description, strconv.Quote(n.parsedAs), strconv.Quote(n.name))
+	}
+
+	e := &TemplateError{
+		Name:        n.name,
+		Line:        line,
+		Col:         col + 1,
+		Chain:       n.Chain(),
+		Description: description,
+		Err:         err,
+	}
+	if n.Source != nil {
+		e.File = n.Source.File
+	}
+	return e
+}
@@ -0,0 +1,30 @@
+package ethttp
+
+import (
+	"context"
+	"net/http"
+
+	et "github.com/gowool/extends-template"
+)
+
+type ctxKey struct{}
+
+// WithEnvironment returns a copy of ctx carrying env
+func WithEnvironment(ctx context.Context, env *et.Environment) context.Context {
+	return context.WithValue(ctx, ctxKey{}, env)
+}
+
+// FromContext returns the environment stored in ctx by WithEnvironment or Middleware
+func FromContext(ctx context.Context) (*et.Environment, bool) {
+	env, ok := ctx.Value(ctxKey{}).(*et.Environment)
+	return env, ok
+}
+
+// Middleware exposes env on the request context of every request
+func Middleware(env *et.Environment) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(WithEnvironment(r.Context(), env)))
+		})
+	}
+}
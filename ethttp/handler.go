@@ -0,0 +1,102 @@
+package ethttp
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"strings"
+
+	et "github.com/gowool/extends-template"
+)
+
+const (
+	defaultSuffix = ".html"
+	defaultIndex  = "index"
+)
+
+// Handler serves templates named after the request path: with prefix "@main/pages/" and the
+// default ".html" suffix, "/about" renders "@main/pages/about.html" and "/blog/" renders
+// "@main/pages/blog/index.html". Paths without a template are answered with 404, other loader
+// errors are served by the error handler of the renderer.
+type Handler struct {
+	renderer *Renderer
+	prefix   string
+	suffix   string
+	index    string
+	data     func(r *http.Request) any
+	static   bool
+}
+
+func NewHandler(renderer *Renderer, prefix string) *Handler {
+	return &Handler{
+		renderer: renderer,
+		prefix:   prefix,
+		suffix:   defaultSuffix,
+		index:    defaultIndex,
+	}
+}
+
+// Suffix sets the extension appended to the request path
+func (h *Handler) Suffix(suffix string) *Handler {
+	h.suffix = suffix
+	return h
+}
+
+// Index sets the template name used for directory paths
+func (h *Handler) Index(index string) *Handler {
+	h.index = index
+	return h
+}
+
+// Data sets the function providing template data for a request
+func (h *Handler) Data(data func(r *http.Request) any) *Handler {
+	h.data = data
+	return h
+}
+
+// Static enables ETag and Last-Modified handling for pages whose output depends only on their templates
+func (h *Handler) Static(static bool) *Handler {
+	h.static = static
+	return h
+}
+
+// Name returns the template name for a request path
+func (h *Handler) Name(urlPath string) string {
+	p := path.Clean("/" + urlPath)
+	if strings.HasSuffix(urlPath, "/") || p == "/" {
+		p = path.Join(p, h.index)
+	}
+	return h.prefix + strings.TrimPrefix(p, "/") + h.suffix
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := h.Name(r.URL.Path)
+
+	// a request path which is no valid template name cannot name a template either
+	ok, err := h.renderer.env.Loader().Exists(r.Context(), name)
+	if err != nil && !errors.Is(err, et.ErrNotFound) && !errors.Is(err, et.ErrInvalidName) {
+		h.renderer.errorHandler(w, r, err, nil)
+		return
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	var data any
+	if h.data != nil {
+		data = h.data(r)
+	}
+
+	if h.static {
+		_ = h.renderer.RenderStatic(w, r, name, data)
+	} else {
+		_ = h.renderer.Render(w, r, http.StatusOK, name, data)
+	}
+}
@@ -0,0 +1,139 @@
+package ethttp_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	et "github.com/gowool/extends-template"
+	"github.com/gowool/extends-template/ethttp"
+)
+
+func TestHandler_Name(t *testing.T) {
+	h := ethttp.NewHandler(nil, "@main/")
+
+	scenarios := map[string]string{
+		"/":           "@main/index.html",
+		"":            "@main/index.html",
+		"/about":      "@main/about.html",
+		"/blog/":      "@main/blog/index.html",
+		"/../../etc/": "@main/etc/index.html",
+	}
+
+	for p, expected := range scenarios {
+		assert.Equal(t, expected, h.Name(p))
+	}
+}
+
+func TestHandler_ServeHTTP(t *testing.T) {
+	env := et.NewEnvironment(et.NewMemoryLoader(views))
+
+	h := ethttp.NewHandler(ethttp.NewRenderer(env), "@main/").Data(func(r *http.Request) any {
+		return r.URL.Query().Get("q")
+	})
+
+	scenarios := []struct {
+		method string
+		target string
+		status int
+		body   string
+	}{
+		{
+			method: http.MethodGet,
+			target: "/?q=query",
+			status: http.StatusOK,
+			body:   "<body>home query</body>",
+		},
+		{
+			method: http.MethodGet,
+			target: "/blog/",
+			status: http.StatusOK,
+			body:   "<body>blog</body>",
+		},
+		{
+			method: http.MethodGet,
+			target: "/missing",
+			status: http.StatusNotFound,
+		},
+		{
+			method: http.MethodGet,
+			target: "/broken",
+			status: http.StatusInternalServerError,
+		},
+		{
+			method: http.MethodPost,
+			target: "/",
+			status: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, s := range scenarios {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(s.method, s.target, nil))
+
+		assert.Equal(t, s.status, rec.Code, s.target)
+		if s.body != "" {
+			assert.Equal(t, s.body, rec.Body.String())
+		}
+	}
+}
+
+func TestHandler_Static(t *testing.T) {
+	env := et.NewEnvironment(et.NewMemoryLoader(views))
+	h := ethttp.NewHandler(ethttp.NewRenderer(env), "@main/").Static(true)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/about", nil))
+
+	if assert.Equal(t, http.StatusOK, rec.Code) && assert.NotEmpty(t, rec.Header().Get("ETag")) {
+		req := httptest.NewRequest(http.MethodGet, "/about", nil)
+		req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotModified, rec.Code)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	env := et.NewEnvironment(et.NewMemoryLoader(views))
+
+	var ok bool
+	h := ethttp.Middleware(env)(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		var actual *et.Environment
+		actual, ok = ethttp.FromContext(r.Context())
+		assert.Same(t, env, actual)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.True(t, ok)
+}
+
+type failingLoader struct {
+	*et.MemoryLoader
+}
+
+func (failingLoader) Exists(context.Context, string) (bool, error) {
+	return false, errors.New("loader is down")
+}
+
+func TestHandler_ServeHTTPLoaderError(t *testing.T) {
+	env := et.NewEnvironment(failingLoader{et.NewMemoryLoader(views)})
+
+	var handled error
+	renderer := ethttp.NewRenderer(env).ErrorHandler(func(w http.ResponseWriter, _ *http.Request, err error, _ any) {
+		handled = err
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	rec := httptest.NewRecorder()
+	ethttp.NewHandler(renderer, "@main/").ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/about", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.EqualError(t, handled, "loader is down")
+}
@@ -0,0 +1,145 @@
+package ethttp
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	et "github.com/gowool/extends-template"
+)
+
+const defaultContentType = "text/html; charset=utf-8"
+
+var bufPool = sync.Pool{
+	New: func() any {
+		return new(bytes.Buffer)
+	},
+}
+
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, err error, data any)
+
+// Renderer executes templates into a buffer before writing the response, so that
+// an execution error results in an error response instead of a half-written page
+type Renderer struct {
+	env          *et.Environment
+	contentType  string
+	errorHandler ErrorHandler
+}
+
+func NewRenderer(env *et.Environment) *Renderer {
+	return &Renderer{
+		env:          env,
+		errorHandler: et.NewErrorPage(env).ServeError,
+	}
+}
+
+// Environment returns the environment templates are rendered with
+func (r *Renderer) Environment() *et.Environment {
+	return r.env
+}
+
+// ContentType overrides the content type, which is otherwise derived from the template name extension
+func (r *Renderer) ContentType(contentType string) *Renderer {
+	r.contentType = contentType
+	return r
+}
+
+// ErrorHandler replaces the default error page
+func (r *Renderer) ErrorHandler(h ErrorHandler) *Renderer {
+	r.errorHandler = h
+	return r
+}
+
+// Render writes the template to w with the given status, or serves the error page if it fails
+func (r *Renderer) Render(w http.ResponseWriter, req *http.Request, status int, name string, data any) error {
+	wrapper, err := r.env.Resolve(WithEnvironment(req.Context(), r.env), name, data)
+	if err != nil {
+		r.errorHandler(w, req, err, data)
+		return err
+	}
+	return r.execute(w, req, status, name, wrapper.Snapshot(), data)
+}
+
+// execute writes a snapshot to w. The wrapper it was taken from may be reparsed meanwhile,
+// the response still matches the headers derived from the snapshot.
+func (r *Renderer) execute(w http.ResponseWriter, req *http.Request, status int, name string, snapshot *et.Snapshot, data any) error {
+	buf := bufPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer bufPool.Put(buf)
+
+	if err := snapshot.Execute(buf, data); err != nil {
+		r.errorHandler(w, req, err, data)
+		return err
+	}
+
+	w.Header().Set("Content-Type", r.contentTypeOf(name))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(status)
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// RenderStatic renders pages whose output depends only on their templates. It sets ETag and
+// Last-Modified from the template dependency set and answers conditional GET and HEAD requests
+// with 304 Not Modified without executing the template.
+func (r *Renderer) RenderStatic(w http.ResponseWriter, req *http.Request, name string, data any) error {
+	wrapper, err := r.env.Resolve(WithEnvironment(req.Context(), r.env), name, data)
+	if err != nil {
+		r.errorHandler(w, req, err, data)
+		return err
+	}
+
+	snapshot := wrapper.Snapshot()
+	etag := strconv.Quote(snapshot.Fingerprint())
+	modTime := snapshot.ModTime()
+
+	w.Header().Set("ETag", etag)
+	if !modTime.IsZero() {
+		w.Header().Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	}
+
+	if (req.Method == http.MethodGet || req.Method == http.MethodHead) && notModified(req, etag, modTime) {
+		h := w.Header()
+		delete(h, "Content-Type")
+		delete(h, "Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	return r.execute(w, req, http.StatusOK, name, snapshot, data)
+}
+
+func (r *Renderer) contentTypeOf(name string) string {
+	if r.contentType != "" {
+		return r.contentType
+	}
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		return contentType
+	}
+	return defaultContentType
+}
+
+func notModified(req *http.Request, etag string, modTime time.Time) bool {
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if modTime.IsZero() {
+		return false
+	}
+
+	ims, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+	return err == nil && !modTime.Truncate(time.Second).After(ims)
+}
@@ -0,0 +1,225 @@
+package ethttp_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	et "github.com/gowool/extends-template"
+	"github.com/gowool/extends-template/ethttp"
+)
+
+var views = map[string][]byte{
+	"@main/layout.html":     []byte(`<body>{{block "content" .}}{{end}}</body>`),
+	"@main/index.html":      []byte(`{{extends "layout.html"}}{{define "content"}}home {{.}}{{end}}`),
+	"@main/about.html":      []byte(`{{extends "layout.html"}}{{define "content"}}about{{end}}`),
+	"@main/blog/index.html": []byte(`{{extends "layout.html"}}{{define "content"}}blog{{end}}`),
+	"@main/broken.html":     []byte(`{{extends "layout.html"}}{{define "content"}}<p>{{.Missing}}</p>{{end}}`),
+	"@main/feed.xml":        []byte(`<feed/>`),
+}
+
+func TestRenderer_Render(t *testing.T) {
+	env := et.NewEnvironment(et.NewMemoryLoader(views))
+
+	scenarios := []struct {
+		name        string
+		contentType string
+		status      int
+		body        string
+		isError     bool
+	}{
+		{
+			name:        "@main/index.html",
+			contentType: "text/html; charset=utf-8",
+			status:      http.StatusOK,
+			body:        "<body>home data</body>",
+		},
+		{
+			name:        "@main/feed.xml",
+			contentType: "text/xml; charset=utf-8",
+			status:      http.StatusOK,
+			body:        "<feed/>",
+		},
+		{
+			name:    "@main/broken.html",
+			status:  http.StatusInternalServerError,
+			isError: true,
+		},
+	}
+
+	for _, s := range scenarios {
+		rec := httptest.NewRecorder()
+
+		err := ethttp.NewRenderer(env).Render(rec, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusOK, s.name, "data")
+
+		assert.Equal(t, s.status, rec.Code)
+		if s.isError {
+			assert.Error(t, err)
+			assert.NotContains(t, rec.Body.String(), "<body>")
+		} else if assert.NoError(t, err) {
+			assert.Equal(t, s.contentType, rec.Header().Get("Content-Type"))
+			assert.Equal(t, s.body, rec.Body.String())
+		}
+	}
+}
+
+func TestRenderer_ContentType(t *testing.T) {
+	env := et.NewEnvironment(et.NewMemoryLoader(views))
+	rec := httptest.NewRecorder()
+
+	err := ethttp.NewRenderer(env).
+		ContentType("application/xhtml+xml").
+		Render(rec, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusAccepted, "@main/about.html", nil)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+		assert.Equal(t, "application/xhtml+xml", rec.Header().Get("Content-Type"))
+	}
+}
+
+func TestRenderer_RenderStatic(t *testing.T) {
+	loader := et.NewMemoryLoader(views)
+	renderer := ethttp.NewRenderer(et.NewEnvironment(loader))
+
+	rec := httptest.NewRecorder()
+	err := renderer.RenderStatic(rec, httptest.NewRequest(http.MethodGet, "/", nil), "@main/about.html", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	etag := rec.Header().Get("ETag")
+	lastModified := rec.Header().Get("Last-Modified")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotEmpty(t, etag)
+	assert.NotEmpty(t, lastModified)
+
+	scenarios := []struct {
+		header string
+		value  string
+		status int
+	}{
+		{
+			header: "If-None-Match",
+			value:  etag,
+			status: http.StatusNotModified,
+		},
+		{
+			header: "If-None-Match",
+			value:  `"other", W/` + etag,
+			status: http.StatusNotModified,
+		},
+		{
+			header: "If-None-Match",
+			value:  `"other"`,
+			status: http.StatusOK,
+		},
+		{
+			header: "If-Modified-Since",
+			value:  lastModified,
+			status: http.StatusNotModified,
+		},
+		{
+			header: "If-Modified-Since",
+			value:  time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat),
+			status: http.StatusOK,
+		},
+	}
+
+	for _, s := range scenarios {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(s.header, s.value)
+
+		rec = httptest.NewRecorder()
+		if assert.NoError(t, renderer.RenderStatic(rec, req, "@main/about.html", nil)) {
+			assert.Equal(t, s.status, rec.Code, s.value)
+			if s.status == http.StatusNotModified {
+				assert.Empty(t, rec.Body.String())
+			}
+		}
+	}
+
+	loader.Add("@main/about.html", []byte(`{{extends "layout.html"}}{{define "content"}}changed{{end}}`))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-None-Match", etag)
+
+	rec = httptest.NewRecorder()
+	if assert.NoError(t, renderer.RenderStatic(rec, req, "@main/about.html", nil)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NotEqual(t, etag, rec.Header().Get("ETag"))
+		assert.Equal(t, "<body>changed</body>", rec.Body.String())
+	}
+}
+
+// changingLoader reports a new version of every template each time it is asked for one
+type changingLoader struct {
+	*et.MemoryLoader
+	version atomic.Int32
+}
+
+func (l *changingLoader) Get(_ context.Context, name string) (*et.Source, error) {
+	version := strconv.Itoa(int(l.version.Load()))
+	return &et.Source{Name: name, Code: []byte("v" + version), Version: version}, nil
+}
+
+func (l *changingLoader) Version(context.Context, string) (string, error) {
+	return strconv.Itoa(int(l.version.Add(1))), nil
+}
+
+func TestRenderer_RenderStaticReparse(t *testing.T) {
+	renderer := ethttp.NewRenderer(et.NewEnvironment(&changingLoader{MemoryLoader: et.NewMemoryLoader(nil)}))
+
+	etags := make(map[string]string)
+	for _, body := range []string{"v0", "v1"} {
+		rec := httptest.NewRecorder()
+		if assert.NoError(t, renderer.RenderStatic(rec, httptest.NewRequest(http.MethodGet, "/", nil), "page.html", nil)) {
+			assert.Equal(t, body, rec.Body.String())
+			etags[rec.Header().Get("ETag")] = body
+		}
+	}
+	assert.Len(t, etags, 2)
+}
+
+func TestRenderer_RenderStaticConcurrentReparse(t *testing.T) {
+	loader := &changingLoader{MemoryLoader: et.NewMemoryLoader(nil)}
+	// in debug mode every load reparses the cached template in place
+	renderer := ethttp.NewRenderer(et.NewEnvironment(loader).Debug(true))
+
+	var (
+		mu     sync.Mutex
+		bodies = make(map[string]string)
+		wg     sync.WaitGroup
+	)
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 200 {
+				loader.version.Add(1)
+
+				rec := httptest.NewRecorder()
+				if err := renderer.RenderStatic(rec, httptest.NewRequest(http.MethodGet, "/", nil), "page.html", nil); err != nil {
+					t.Error(err)
+					return
+				}
+
+				mu.Lock()
+				etag := rec.Header().Get("ETag")
+				if body, ok := bodies[etag]; ok && body != rec.Body.String() {
+					t.Errorf("ETag %s is sent with %q and %q", etag, body, rec.Body.String())
+				}
+				bodies[etag] = rec.Body.String()
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -34,12 +34,7 @@ func main() {
 
 	view := "@test_ns/views/home.html"
 
-	w, err := e.Load(context.TODO(), view)
-	if err != nil {
-		panic(err)
-	}
-
-	if err = w.HTML.ExecuteTemplate(os.Stdout, view, nil); err != nil {
+	if err = e.Render(context.TODO(), os.Stdout, view, nil); err != nil {
 		panic(err)
 	}
 }
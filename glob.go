@@ -0,0 +1,93 @@
+package et
+
+import (
+	"context"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Match reports whether a template name matches a shell pattern. Patterns use the syntax
+// of path.Match applied to slash-separated segments, and a "**" segment matches any number
+// of segments, including none, e.g. "@main/**/*.html" matches "@main/views/home.html".
+func Match(pattern, name string) (bool, error) {
+	segments := strings.Split(pattern, "/")
+	for _, segment := range segments {
+		if _, err := path.Match(segment, ""); err != nil {
+			return false, err
+		}
+	}
+	return match(segments, strings.Split(name, "/")), nil
+}
+
+// Glob returns the sorted names of the templates served by lister which match any of patterns.
+// Without patterns all templates are returned.
+func Glob(ctx context.Context, lister Lister, patterns ...string) ([]string, error) {
+	names, err := lister.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(patterns) == 0 {
+		return names, nil
+	}
+
+	matches := make([]string, 0, len(names))
+	for _, name := range names {
+		for _, pattern := range patterns {
+			ok, err := Match(pattern, name)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				matches = append(matches, name)
+				break
+			}
+		}
+	}
+	return matches, nil
+}
+
+func match(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := range len(name) + 1 {
+				if match(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// inNamespace reports whether a template name belongs to namespace, any name belongs to the empty one
+func inNamespace(name, namespace string) bool {
+	if namespace == "" {
+		return true
+	}
+	if data := strings.SplitN(name, "/", 2); len(data) == 2 && '@' == data[0][0] {
+		return data[0][1:] == namespace
+	}
+	return namespace == BaseNamespace
+}
+
+func sortedNames(set map[string]struct{}) []string {
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
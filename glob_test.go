@@ -0,0 +1,60 @@
+package et_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	et "github.com/gowool/extends-template"
+)
+
+func TestMatch(t *testing.T) {
+	scenarios := []struct {
+		pattern string
+		name    string
+		match   bool
+	}{
+		{pattern: "*.html", name: "layout.html", match: true},
+		{pattern: "*.html", name: "views/home.html", match: false},
+		{pattern: "**/*.html", name: "layout.html", match: true},
+		{pattern: "**/*.html", name: "views/home.html", match: true},
+		{pattern: "@main/**", name: "@main/views/home.html", match: true},
+		{pattern: "@main/**/*.html", name: "@main/views/home.html", match: true},
+		{pattern: "@main/**/*.html", name: "@base/views/home.html", match: false},
+		{pattern: "@*/views/**/*.html", name: "@main/views/a/b/home.html", match: true},
+		{pattern: "views/**/home.txt", name: "views/a/home.html", match: false},
+		{pattern: "views/?ome.html", name: "views/home.html", match: true},
+	}
+
+	for _, s := range scenarios {
+		ok, err := et.Match(s.pattern, s.name)
+
+		assert.NoError(t, err)
+		assert.Equal(t, s.match, ok, "%s ~ %s", s.pattern, s.name)
+	}
+
+	_, err := et.Match("**/[", "layout.html")
+	assert.Error(t, err)
+}
+
+func TestGlob(t *testing.T) {
+	loader := et.NewMemoryLoader(map[string][]byte{
+		"layout.html":           nil,
+		"views/home.html":       nil,
+		"@main/views/home.html": nil,
+		"@main/views/menu.txt":  nil,
+	})
+
+	names, err := et.Glob(context.TODO(), loader, "**/*.html")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"@main/views/home.html", "layout.html", "views/home.html"}, names)
+
+	names, err = et.Glob(context.TODO(), loader, "@main/**", "layout.html")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"@main/views/home.html", "@main/views/menu.txt", "layout.html"}, names)
+
+	names, err = et.Glob(context.TODO(), loader)
+	assert.NoError(t, err)
+	assert.Len(t, names, 4)
+}
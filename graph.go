@@ -0,0 +1,216 @@
+package et
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gowool/extends-template/internal"
+)
+
+// Kinds of graph nodes
+const (
+	KindEntry   = "entry"
+	KindLayout  = "layout"
+	KindPartial = "partial"
+	KindGlobal  = "global"
+	KindOrphan  = "orphan"
+)
+
+// Kinds of graph edges
+const (
+	EdgeExtends = "extends"
+	EdgeInclude = "include"
+	EdgeGlobal  = "global"
+)
+
+// GraphNode is a template of a Graph
+type GraphNode struct {
+	Name  string `json:"name"`
+	Kind  string `json:"kind"`
+	File  string `json:"file,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// GraphEdge is a dependency of a template on another one
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Kind string `json:"kind"`
+}
+
+// Graph is the inheritance graph of a set of entry templates: the layouts they extend,
+// the partials they include and the globals, together with the orphaned templates of
+// the loader no entry template depends on
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// Graph loads the templates matching any of patterns and returns their inheritance graph.
+// Without patterns, the entries are the templates no other template extends or includes and
+// which use other templates themselves, the others are orphans. Templates failing to load
+// are part of the graph with their error, and the failures are returned joined. The loader
+// must be a Lister.
+func (e *Environment) Graph(ctx context.Context, patterns ...string) (*Graph, error) {
+	lister, ok := e.loader.(Lister)
+	if !ok {
+		return nil, fmt.Errorf("graph: loader %s is not a Lister", internal.TypeName(e.loader))
+	}
+
+	all, err := lister.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	entries, _, err := e.graphEntries(ctx, lister, patterns...)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.RLock()
+	globals := slices.Clone(e.global)
+	e.mu.RUnlock()
+
+	nodes := make(map[string]*GraphNode)
+	edges := make(map[GraphEdge]struct{})
+
+	node := func(n *Node, kind string) {
+		gn, ok := nodes[n.name]
+		if !ok {
+			gn = &GraphNode{Name: n.name, Kind: kind}
+			nodes[n.name] = gn
+		}
+		if gn.File == "" && n.Source != nil {
+			gn.File = n.Source.File
+		}
+		if kindRank(kind) < kindRank(gn.Kind) {
+			gn.Kind = kind
+		}
+	}
+
+	var errs []error
+	for _, name := range entries {
+		w, err := e.Load(ctx, name)
+		if err != nil {
+			nodes[name] = &GraphNode{Name: name, Kind: KindEntry, Error: err.Error()}
+			errs = append(errs, err)
+			continue
+		}
+
+		root := w.Snapshot().Root()
+		global := root.Includes[:min(len(globals), len(root.Includes))]
+
+		root.Walk(func(n *Node) {
+			switch {
+			case n.name == w.name:
+				node(n, KindEntry)
+			case slices.Contains(global, n):
+				node(n, KindGlobal)
+			case n.Successor != nil:
+				node(n, KindLayout)
+			default:
+				node(n, KindPartial)
+			}
+
+			if n.Extends != nil {
+				edges[GraphEdge{From: n.name, To: n.Extends.name, Kind: EdgeExtends}] = struct{}{}
+			}
+			for i, include := range n.Includes {
+				kind := EdgeInclude
+				if n == root && i < len(global) {
+					kind = EdgeGlobal
+				}
+				edges[GraphEdge{From: n.name, To: include.name, Kind: kind}] = struct{}{}
+			}
+		})
+	}
+
+	for _, name := range all {
+		if _, ok := nodes[name]; !ok && !nodeAliased(nodes, name) {
+			nodes[name] = &GraphNode{Name: name, Kind: KindOrphan}
+		}
+	}
+
+	g := &Graph{Nodes: make([]GraphNode, 0, len(nodes)), Edges: make([]GraphEdge, 0, len(edges))}
+	for _, n := range nodes {
+		g.Nodes = append(g.Nodes, *n)
+	}
+	for edge := range edges {
+		g.Edges = append(g.Edges, edge)
+	}
+
+	slices.SortFunc(g.Nodes, func(a, b GraphNode) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	slices.SortFunc(g.Edges, func(a, b GraphEdge) int {
+		return cmp.Or(cmp.Compare(a.From, b.From), cmp.Compare(a.To, b.To), cmp.Compare(a.Kind, b.Kind))
+	})
+
+	return g, errors.Join(errs...)
+}
+
+// WriteJSON writes the graph as indented JSON
+func (g *Graph) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
+
+// WriteDOT writes the graph in the Graphviz DOT language
+func (g *Graph) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+
+	b.WriteString("digraph templates {\n")
+	b.WriteString("\trankdir=LR;\n")
+	b.WriteString("\tnode [shape=box, fontname=\"sans-serif\"];\n")
+	for _, n := range g.Nodes {
+		style := dotNodeStyle[n.Kind]
+		if n.Error != "" {
+			style += `, color="red", tooltip=` + strconv.Quote(n.Error)
+		}
+		fmt.Fprintf(&b, "\t%s [%s];\n", strconv.Quote(n.Name), style)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "\t%s -> %s [%s];\n", strconv.Quote(e.From), strconv.Quote(e.To), dotEdgeStyle[e.Kind])
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var (
+	dotNodeStyle = map[string]string{
+		KindEntry:   `style="bold"`,
+		KindLayout:  `style="filled", fillcolor="lightblue"`,
+		KindPartial: `style="solid"`,
+		KindGlobal:  `style="dashed"`,
+		KindOrphan:  `style="filled", fillcolor="lightpink"`,
+	}
+	dotEdgeStyle = map[string]string{
+		EdgeExtends: `label="extends"`,
+		EdgeInclude: `label="include", style="dashed"`,
+		EdgeGlobal:  `label="global", style="dotted"`,
+	}
+)
+
+// kindRank orders node kinds by precedence, a template used in several roles gets the first one
+func kindRank(kind string) int {
+	return slices.Index([]string{KindEntry, KindGlobal, KindLayout, KindPartial, KindOrphan}, kind)
+}
+
+// nodeAliased reports whether a base namespace template is in the graph under its prefixed name
+func nodeAliased(nodes map[string]*GraphNode, name string) bool {
+	if strings.HasPrefix(name, "@") {
+		return false
+	}
+	_, ok := nodes["@"+BaseNamespace+"/"+name]
+	return ok
+}
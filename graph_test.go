@@ -0,0 +1,95 @@
+package et_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	et "github.com/gowool/extends-template"
+)
+
+func newGraphEnvironment() *et.Environment {
+	return et.NewEnvironment(et.NewMemoryLoader(map[string][]byte{
+		"@main/global.html":       []byte(`{{define "title"}}title{{end}}`),
+		"@main/layout.html":       []byte(`<body>{{template "partials/nav.html"}}{{block "content" .}}{{end}}</body>`),
+		"@main/partials/nav.html": []byte(`<nav></nav>`),
+		"@main/partials/old.html": []byte(`<old></old>`),
+		"@main/pages/home.html":   []byte(`{{extends "layout.html"}}{{define "content"}}home{{end}}`),
+		"@main/pages/broken.html": []byte(`{{extends "missing.html"}}`),
+	})).Global("@main/global.html")
+}
+
+func TestEnvironment_Graph(t *testing.T) {
+	for _, patterns := range [][]string{{"@main/pages/**"}, nil} {
+		t.Run(fmt.Sprint(patterns), func(t *testing.T) {
+			testGraph(t, patterns...)
+		})
+	}
+}
+
+func testGraph(t *testing.T, patterns ...string) {
+	g, err := newGraphEnvironment().Graph(context.TODO(), patterns...)
+
+	var loadErr *et.LoadError
+	if assert.ErrorAs(t, err, &loadErr) {
+		assert.Equal(t, "@main/pages/broken.html", loadErr.Name)
+	}
+	if !assert.NotNil(t, g) {
+		return
+	}
+
+	kinds := make(map[string]string)
+	for _, n := range g.Nodes {
+		kinds[n.Name] = n.Kind
+		assert.Equal(t, n.Name == "@main/pages/broken.html", n.Error != "", n.Name)
+	}
+	assert.Equal(t, map[string]string{
+		"@main/global.html":       et.KindGlobal,
+		"@main/layout.html":       et.KindLayout,
+		"@main/partials/nav.html": et.KindPartial,
+		"@main/partials/old.html": et.KindOrphan,
+		"@main/pages/home.html":   et.KindEntry,
+		"@main/pages/broken.html": et.KindEntry,
+	}, kinds)
+
+	assert.Equal(t, []et.GraphEdge{
+		{From: "@main/layout.html", To: "@main/global.html", Kind: et.EdgeGlobal},
+		{From: "@main/layout.html", To: "@main/partials/nav.html", Kind: et.EdgeInclude},
+		{From: "@main/pages/home.html", To: "@main/layout.html", Kind: et.EdgeExtends},
+	}, g.Edges)
+}
+
+func TestGraph_WriteDOT(t *testing.T) {
+	g, _ := newGraphEnvironment().Graph(context.TODO(), "@main/pages/home.html")
+
+	var out bytes.Buffer
+	if assert.NoError(t, g.WriteDOT(&out)) {
+		assert.Contains(t, out.String(), "digraph templates {\n")
+		assert.Contains(t, out.String(), `"@main/pages/home.html" -> "@main/layout.html" [label="extends"];`)
+		assert.Contains(t, out.String(), `"@main/partials/old.html" [style="filled", fillcolor="lightpink"];`)
+	}
+}
+
+func TestGraph_WriteJSON(t *testing.T) {
+	g, _ := newGraphEnvironment().Graph(context.TODO(), "@main/pages/home.html")
+
+	var out bytes.Buffer
+	if !assert.NoError(t, g.WriteJSON(&out)) {
+		return
+	}
+
+	var decoded et.Graph
+	if assert.NoError(t, json.Unmarshal(out.Bytes(), &decoded)) {
+		assert.Equal(t, *g, decoded)
+	}
+}
+
+func TestEnvironment_GraphNotLister(t *testing.T) {
+	_, err := et.NewEnvironment(wrapLoader{}).Graph(context.TODO())
+
+	assert.Error(t, err)
+}
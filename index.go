@@ -0,0 +1,178 @@
+package et
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/gowool/extends-template/internal"
+)
+
+// templateIndex relates the templates of a loader by their static extends and include actions,
+// without parsing them. Extends expressions are not taken into account.
+type templateIndex struct {
+	// names are the sorted names of all templates
+	names []string
+
+	// refs are the templates each scanned template extends or includes, by canonical name
+	refs map[string][]string
+
+	// referenced are the canonical names of the templates extended or included by another one
+	referenced map[string]struct{}
+
+	// standalone are the scanned templates which neither extend nor include a template
+	standalone map[string]struct{}
+
+	// calls are the names each scanned template renders which it does not define and which are
+	// no templates of the loader
+	calls map[string][]string
+
+	// defines are the names defined by any scanned template
+	defines map[string]struct{}
+}
+
+// index lists and scans all templates of the loader. Templates which cannot be read or scanned
+// are indexed without references, loading them reports the failure.
+func (e *Environment) index(ctx context.Context, lister Lister) (*templateIndex, error) {
+	names, err := lister.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.RLock()
+	left, right, globals := e.left, e.right, e.global
+	e.mu.RUnlock()
+
+	x := &templateIndex{
+		names:      names,
+		refs:       make(map[string][]string, len(names)),
+		referenced: make(map[string]struct{}),
+		standalone: make(map[string]struct{}),
+		calls:      make(map[string][]string),
+		defines:    make(map[string]struct{}),
+	}
+
+	exists := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		exists[canonicalName(name)] = struct{}{}
+	}
+
+	for _, name := range names {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		r := &resolver{loader: e.loader, ns: namespaceOf(name)}
+		for _, global := range globals {
+			x.referenced[canonicalName(qualify(r.ns, global))] = struct{}{}
+		}
+
+		source, err := e.loader.Get(ctx, name)
+		if err != nil {
+			continue
+		}
+		d, err := internal.Scan(name, source.Code, left, right)
+		if err != nil {
+			continue
+		}
+
+		for _, define := range d.Defines {
+			x.defines[define] = struct{}{}
+		}
+
+		directives := append(d.Extends, d.Includes...)
+		if len(directives) == 0 {
+			x.standalone[name] = struct{}{}
+		}
+
+		refs := make([]string, 0)
+		for _, directive := range directives {
+			if directive.Expr != "" {
+				continue
+			}
+			ref, err := r.name(name, directive)
+			if err != nil {
+				continue
+			}
+			if ref = canonicalName(ref); ref != canonicalName(name) {
+				if _, ok := exists[ref]; ok {
+					refs = append(refs, ref)
+					x.referenced[ref] = struct{}{}
+				} else if !slices.Contains(d.Extends, directive) {
+					x.calls[name] = append(x.calls[name], directive.Name)
+				}
+			}
+		}
+		x.refs[name] = refs
+	}
+	return x, nil
+}
+
+// roots returns the templates no other template extends or includes. Templates rendering a
+// template defined by another one are left out too: they are layouts of an extends expression.
+func (x *templateIndex) roots() []string {
+	var roots []string
+	for _, name := range x.names {
+		if _, ok := x.referenced[canonicalName(name)]; ok {
+			continue
+		}
+		if slices.ContainsFunc(x.calls[name], func(call string) bool {
+			_, ok := x.defines[call]
+			return ok
+		}) {
+			continue
+		}
+		roots = append(roots, name)
+	}
+	return roots
+}
+
+// graphRoots returns the templates no other template extends or includes, split into entries,
+// which use other templates or cannot be scanned, and orphans
+func (x *templateIndex) graphRoots() (entries, orphans []string) {
+	for _, name := range x.roots() {
+		if _, ok := x.standalone[name]; ok {
+			orphans = append(orphans, name)
+		} else {
+			entries = append(entries, name)
+		}
+	}
+	return entries, orphans
+}
+
+// graphEntries returns the templates of the loader matching any of patterns. Without patterns
+// these are the entries of graphRoots, together with its orphans.
+func (e *Environment) graphEntries(ctx context.Context, lister Lister, patterns ...string) (entries, orphans []string, err error) {
+	if len(patterns) > 0 {
+		entries, err = Glob(ctx, lister, patterns...)
+		return entries, nil, err
+	}
+
+	x, err := e.index(ctx, lister)
+	if err != nil {
+		return nil, nil, err
+	}
+	entries, orphans = x.graphRoots()
+	return entries, orphans, nil
+}
+
+// entries returns the templates of the loader matching any of patterns. Without patterns these
+// are the templates no other template extends or includes: layouts and partials may use
+// templates defined by their children and cannot be loaded on their own.
+func (e *Environment) entries(ctx context.Context, lister Lister, patterns ...string) ([]string, error) {
+	if len(patterns) > 0 {
+		return Glob(ctx, lister, patterns...)
+	}
+
+	x, err := e.index(ctx, lister)
+	if err != nil {
+		return nil, err
+	}
+	return x.roots(), nil
+}
+
+// canonicalName returns the name of a base namespace template without its prefix, the way
+// loaders list it
+func canonicalName(name string) string {
+	return strings.TrimPrefix(name, "@"+BaseNamespace+"/")
+}
@@ -4,22 +4,8 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"reflect"
-	"regexp"
 )
 
-const (
-	extendsPattern  = `%s\s*extends\s*"(.*?)"\s*%s`
-	templatePattern = `%s.*?template\s*"(.*?)".*?%s`
-)
-
-func ReExtends(left, right string) *regexp.Regexp {
-	return regexp.MustCompile(fmt.Sprintf(extendsPattern, left, right))
-}
-
-func ReTemplate(left, right string) *regexp.Regexp {
-	return regexp.MustCompile(fmt.Sprintf(templatePattern, left, right))
-}
-
 func TypeName(i any) string {
 	t := reflect.TypeOf(i)
 
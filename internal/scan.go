@@ -0,0 +1,367 @@
+package internal
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template/parse"
+)
+
+const (
+	extendsKeyword = "extends"
+	parentKeyword  = "parent"
+)
+
+// Directive is a template reference found in a source
+type Directive struct {
+	// Name is the referenced template name
+	Name string
+
+	// Expr is the source of a template name evaluated at render time, Name is empty then
+	Expr string
+
+	// Pos and End are the byte offsets of the quoted name in the source, End equals Pos for an Expr
+	Pos int
+	End int
+}
+
+// Parent is a parent action inside a define or block, rendering the overridden definition
+type Parent struct {
+	// Define is the name of the template the action is in
+	Define string
+
+	// Pos and End are the byte offsets of the parent keyword in the source
+	Pos int
+	End int
+}
+
+// Directives describes the extends and template references of a source
+type Directives struct {
+	// Extends are the candidate layouts of a top-level extends action, if any, in order of preference
+	Extends []Directive
+
+	// ExtendsPos and ExtendsEnd are the byte offsets of the whole extends action, including delimiters
+	ExtendsPos int
+	ExtendsEnd int
+
+	// Includes are the template actions whose names are not defined in the source itself
+	Includes []Directive
+
+	// Templates are all template and block actions, including those of templates defined in the source
+	Templates []Directive
+
+	// Parents are the parent actions of the source
+	Parents []Parent
+
+	// Funcs are the function identifiers used in the source, the extends and parent pseudo functions excluded
+	Funcs []Directive
+
+	// Defines are the names of templates defined in the source with define or block
+	Defines []string
+
+	// Trees are the parse trees of the source, keyed by template name
+	Trees map[string]*parse.Tree
+}
+
+// Scan parses code with text/template/parse and extracts its directives.
+// Function names are not checked, so the extends pseudo function parses as a regular call.
+func Scan(name string, code []byte, left, right string) (*Directives, error) {
+	text := String(code)
+
+	t := parse.New(name)
+	t.Mode = parse.SkipFuncCheck
+
+	trees := make(map[string]*parse.Tree)
+	if _, err := t.Parse(text, left, right, trees); err != nil {
+		return nil, err
+	}
+
+	d := &Directives{Trees: trees}
+
+	for treeName := range trees {
+		if treeName != name {
+			d.Defines = append(d.Defines, treeName)
+		}
+	}
+
+	if root, ok := trees[name]; ok && root.Root != nil {
+		for _, node := range root.Root.Nodes {
+			action, ok := node.(*parse.ActionNode)
+			if !ok || !isExtends(action) {
+				continue
+			}
+
+			if d.Extends != nil {
+				return nil, fmt.Errorf("template: %s: multiple extends actions", name)
+			}
+
+			args := action.Pipe.Cmds[0].Args[1:]
+			if len(args) == 0 {
+				return nil, fmt.Errorf("template: %s: extends expects at least one template name", name)
+			}
+
+			d.Extends = make([]Directive, 0, len(args))
+			for _, arg := range args {
+				var directive Directive
+				if str, ok := arg.(*parse.StringNode); ok {
+					var err error
+					if directive, err = quoted(text, str.Pos, str.Text); err != nil {
+						return nil, err
+					}
+				} else {
+					directive = Directive{Expr: arg.String(), Pos: int(arg.Position()), End: int(arg.Position())}
+				}
+				d.Extends = append(d.Extends, directive)
+			}
+
+			last := d.Extends[len(d.Extends)-1].End
+			start := strings.LastIndex(text[:action.Pos], left)
+			end := strings.Index(text[last:], right)
+			if start < 0 || end < 0 {
+				return nil, fmt.Errorf("template: %s: malformed extends action", name)
+			}
+
+			d.ExtendsPos = start
+			d.ExtendsEnd = last + end + len(right)
+		}
+	}
+
+	for _, tree := range trees {
+		if err := walk(tree.Root, func(node *parse.TemplateNode) error {
+			directive, err := quoted(text, node.Pos, node.Name)
+			if err != nil {
+				return err
+			}
+
+			d.Templates = append(d.Templates, directive)
+			if _, ok := trees[node.Name]; !ok {
+				d.Includes = append(d.Includes, directive)
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+
+		var parents []Parent
+		inspect(tree.Root, func(node parse.Node) {
+			if action, ok := node.(*parse.ActionNode); ok && isParent(action) {
+				pos := int(action.Pipe.Cmds[0].Args[0].Position())
+				parents = append(parents, Parent{Define: tree.Name, Pos: pos, End: pos + len(parentKeyword)})
+			}
+		})
+		if len(parents) > 0 && tree.Name == name {
+			return nil, fmt.Errorf("template: %s: parent action outside of a define or block", name)
+		}
+		d.Parents = append(d.Parents, parents...)
+
+		inspect(tree.Root, func(node parse.Node) {
+			ident, ok := node.(*parse.IdentifierNode)
+			if !ok || (d.Extends != nil && int(ident.Pos) >= d.ExtendsPos && int(ident.Pos) < d.ExtendsEnd) {
+				return
+			}
+			if slices.ContainsFunc(parents, func(p Parent) bool { return p.Pos == int(ident.Pos) }) {
+				return
+			}
+			d.Funcs = append(d.Funcs, Directive{Name: ident.Ident, Pos: int(ident.Pos), End: int(ident.Pos) + len(ident.Ident)})
+		})
+	}
+
+	slices.Sort(d.Defines)
+	for _, directives := range [][]Directive{d.Includes, d.Templates, d.Funcs} {
+		slices.SortFunc(directives, func(a, b Directive) int {
+			return cmp.Compare(a.Pos, b.Pos)
+		})
+	}
+	slices.SortFunc(d.Parents, func(a, b Parent) int {
+		return cmp.Compare(a.Pos, b.Pos)
+	})
+
+	return d, nil
+}
+
+// Edit replaces the source bytes between Pos and End with Text
+type Edit struct {
+	Pos  int
+	End  int
+	Text string
+}
+
+// Blank returns an edit removing the bytes between pos and end but keeping their line breaks,
+// so that line numbers of the remaining source do not change
+func Blank(code []byte, pos, end int) Edit {
+	return Edit{Pos: pos, End: end, Text: strings.Repeat("\n", bytes.Count(code[pos:end], []byte{'\n'}))}
+}
+
+// Apply returns a copy of code with the non-overlapping edits applied
+func Apply(code []byte, edits []Edit) []byte {
+	edits = slices.Clone(edits)
+	slices.SortFunc(edits, func(a, b Edit) int {
+		return cmp.Compare(a.Pos, b.Pos)
+	})
+
+	out := make([]byte, 0, len(code))
+	last := 0
+	for _, e := range edits {
+		out = append(out, code[last:e.Pos]...)
+		out = append(out, e.Text...)
+		last = e.End
+	}
+	return append(out, code[last:]...)
+}
+
+// Origin maps an offset in code edited by Apply back to an offset in the original code
+func Origin(edits []Edit, offset int) int {
+	edits = slices.Clone(edits)
+	slices.SortFunc(edits, func(a, b Edit) int {
+		return cmp.Compare(a.Pos, b.Pos)
+	})
+
+	delta := 0
+	for _, e := range edits {
+		pos := e.Pos + delta
+		if offset < pos {
+			break
+		}
+		if offset < pos+len(e.Text) {
+			return e.Pos
+		}
+		delta += len(e.Text) - (e.End - e.Pos)
+	}
+	return offset - delta
+}
+
+// Offset returns the byte offset of a 1-based line and a 0-based column in code
+func Offset(code []byte, line, col int) int {
+	offset := 0
+	for ; line > 1; line-- {
+		i := bytes.IndexByte(code[offset:], '\n')
+		if i < 0 {
+			break
+		}
+		offset += i + 1
+	}
+	return min(offset+col, len(code))
+}
+
+// Position returns the 1-based line and the 0-based column of a byte offset in code
+func Position(code []byte, offset int) (int, int) {
+	code = code[:min(offset, len(code))]
+
+	line := 1 + bytes.Count(code, []byte{'\n'})
+	return line, len(code) - (bytes.LastIndexByte(code, '\n') + 1)
+}
+
+func isExtends(action *parse.ActionNode) bool {
+	if action.Pipe == nil || len(action.Pipe.Decl) > 0 || len(action.Pipe.Cmds) != 1 {
+		return false
+	}
+
+	args := action.Pipe.Cmds[0].Args
+	if len(args) == 0 {
+		return false
+	}
+
+	ident, ok := args[0].(*parse.IdentifierNode)
+	return ok && ident.Ident == extendsKeyword
+}
+
+// isParent reports whether the action is a bare parent call
+func isParent(action *parse.ActionNode) bool {
+	if action.Pipe == nil || len(action.Pipe.Decl) > 0 || len(action.Pipe.Cmds) != 1 {
+		return false
+	}
+
+	args := action.Pipe.Cmds[0].Args
+	if len(args) != 1 {
+		return false
+	}
+
+	ident, ok := args[0].(*parse.IdentifierNode)
+	return ok && ident.Ident == parentKeyword
+}
+
+func quoted(text string, pos parse.Pos, name string) (Directive, error) {
+	q, err := strconv.QuotedPrefix(text[pos:])
+	if err != nil {
+		return Directive{}, fmt.Errorf("template name %q at offset %d: %w", name, pos, err)
+	}
+	return Directive{Name: name, Pos: int(pos), End: int(pos) + len(q)}, nil
+}
+
+func walk(node parse.Node, fn func(node *parse.TemplateNode) error) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := walk(child, fn); err != nil {
+				return err
+			}
+		}
+	case *parse.IfNode:
+		return walkBranch(&n.BranchNode, fn)
+	case *parse.RangeNode:
+		return walkBranch(&n.BranchNode, fn)
+	case *parse.WithNode:
+		return walkBranch(&n.BranchNode, fn)
+	case *parse.TemplateNode:
+		return fn(n)
+	}
+	return nil
+}
+
+func walkBranch(n *parse.BranchNode, fn func(node *parse.TemplateNode) error) error {
+	if err := walk(n.List, fn); err != nil {
+		return err
+	}
+	return walk(n.ElseList, fn)
+}
+
+// inspect calls fn for node and all nodes below it
+func inspect(node parse.Node, fn func(node parse.Node)) {
+	fn(node)
+
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			inspect(child, fn)
+		}
+	case *parse.ActionNode:
+		inspect(n.Pipe, fn)
+	case *parse.IfNode:
+		inspectBranch(&n.BranchNode, fn)
+	case *parse.RangeNode:
+		inspectBranch(&n.BranchNode, fn)
+	case *parse.WithNode:
+		inspectBranch(&n.BranchNode, fn)
+	case *parse.TemplateNode:
+		if n.Pipe != nil {
+			inspect(n.Pipe, fn)
+		}
+	case *parse.PipeNode:
+		for _, cmd := range n.Cmds {
+			inspect(cmd, fn)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			inspect(arg, fn)
+		}
+	case *parse.ChainNode:
+		inspect(n.Node, fn)
+	}
+}
+
+func inspectBranch(n *parse.BranchNode, fn func(node parse.Node)) {
+	inspect(n.Pipe, fn)
+	inspect(n.List, fn)
+	if n.ElseList != nil {
+		inspect(n.ElseList, fn)
+	}
+}
@@ -0,0 +1,215 @@
+package internal_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/gowool/extends-template/internal"
+)
+
+func TestScan(t *testing.T) {
+	scenarios := []struct {
+		name     string
+		code     string
+		left     string
+		right    string
+		extends  string
+		includes []string
+		defines  []string
+		isError  bool
+	}{
+		{
+			name: "comment",
+			code: `{{/* {{template "x.html"}} */}}<p>text</p>`,
+		},
+		{
+			name: "string literal",
+			code: `{{"{{template \"x.html\"}}"}}{{printf "%s" "{{extends \"y.html\"}}"}}`,
+		},
+		{
+			name:    "local define",
+			code:    `{{define "content"}}<p>{{.}}</p>{{end}}{{template "content" .}}`,
+			defines: []string{"content"},
+		},
+		{
+			name:    "block",
+			code:    `<nav>{{block "nav" .}}{{template "menu" .}}{{end}}</nav>{{define "menu"}}{{end}}`,
+			defines: []string{"menu", "nav"},
+		},
+		{
+			name:     "branches",
+			code:     `{{if .}}{{template "a.html"}}{{else}}{{template "b.html" .}}{{end}}{{range .}}{{with .}}{{template "c.html"}}{{end}}{{end}}`,
+			includes: []string{"a.html", "b.html", "c.html"},
+		},
+		{
+			name:     "raw string and duplicates",
+			code:     "{{template `d.html`}}{{template \"d.html\"}}",
+			includes: []string{"d.html", "d.html"},
+		},
+		{
+			name:     "extends with trim markers",
+			code:     "{{- extends \"layout.html\" -}}\n{{define \"content\"}}{{template \"p.html\"}}{{end}}",
+			extends:  "layout.html",
+			includes: []string{"p.html"},
+			defines:  []string{"content"},
+		},
+		{
+			name:     "custom delims",
+			code:     `[[extends "layout.html"]][[define "content"]]{{template "x.html"}}[[template "p.html"]][[end]]`,
+			left:     "[[",
+			right:    "]]",
+			extends:  "layout.html",
+			includes: []string{"p.html"},
+			defines:  []string{"content"},
+		},
+		{
+			name:    "extends inside define is not a directive",
+			code:    `{{define "content"}}{{extends "layout.html"}}{{end}}`,
+			defines: []string{"content"},
+		},
+		{
+			name:    "multiple extends",
+			code:    `{{extends "a.html"}}{{extends "b.html"}}`,
+			isError: true,
+		},
+		{
+			name:    "extends without name",
+			code:    `{{extends}}`,
+			isError: true,
+		},
+		{
+			name:    "syntax error",
+			code:    `{{if}}`,
+			isError: true,
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			left, right := s.left, s.right
+			if left == "" {
+				left, right = "{{", "}}"
+			}
+
+			d, err := internal.Scan("view.html", []byte(s.code), left, right)
+			if s.isError {
+				assert.Error(t, err)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			if s.extends == "" {
+				assert.Nil(t, d.Extends)
+			} else if assert.Len(t, d.Extends, 1) {
+				assert.Equal(t, s.extends, d.Extends[0].Name)
+			}
+
+			var includes []string
+			for _, include := range d.Includes {
+				includes = append(includes, include.Name)
+				assert.Contains(t, s.code[include.Pos:include.End], include.Name)
+			}
+			assert.Equal(t, s.includes, includes)
+			assert.Equal(t, s.defines, d.Defines)
+		})
+	}
+}
+
+func TestScan_ExtendsCandidates(t *testing.T) {
+	code := `{{- extends .Layout "mobile.html" (printf "%s.html" .Name) -}}{{define "content"}}{{end}}`
+
+	d, err := internal.Scan("view.html", []byte(code), "{{", "}}")
+	if !assert.NoError(t, err) || !assert.Len(t, d.Extends, 3) {
+		return
+	}
+
+	assert.Equal(t, internal.Directive{Expr: ".Layout", Pos: 12, End: 12}, d.Extends[0])
+	assert.Equal(t, "mobile.html", d.Extends[1].Name)
+	assert.Equal(t, `printf "%s.html" .Name`, d.Extends[2].Expr)
+	assert.Equal(t, `{{define "content"}}{{end}}`, code[d.ExtendsEnd:])
+	assert.Empty(t, d.Funcs)
+}
+
+func TestScan_TemplatesAndFuncs(t *testing.T) {
+	code := `{{extends "layout.html"}}{{define "content"}}{{block "nav" .}}{{upper .Title | lower}}{{end}}` +
+		`{{if eq (len .Items) 0}}{{template "empty.html" (dict "a" 1)}}{{end}}{{extends "x.html"}}{{end}}`
+
+	d, err := internal.Scan("view.html", []byte(code), "{{", "}}")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var templates []string
+	for _, directive := range d.Templates {
+		templates = append(templates, directive.Name)
+	}
+	assert.Equal(t, []string{"nav", "empty.html"}, templates)
+
+	var funcs []string
+	for _, directive := range d.Funcs {
+		funcs = append(funcs, directive.Name)
+		assert.Equal(t, directive.Name, code[directive.Pos:directive.End])
+	}
+	assert.Equal(t, []string{"upper", "lower", "eq", "len", "dict", "extends"}, funcs)
+}
+
+func TestScan_Parents(t *testing.T) {
+	code := `{{define "a"}}{{parent}}{{if .}}{{- parent -}}{{end}}{{end}}{{define "b"}}{{parent .}}{{$x := parent}}{{end}}`
+
+	d, err := internal.Scan("view.html", []byte(code), "{{", "}}")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	if assert.Len(t, d.Parents, 2) {
+		for _, p := range d.Parents {
+			assert.Equal(t, "a", p.Define)
+			assert.Equal(t, "parent", code[p.Pos:p.End])
+		}
+	}
+
+	var funcs []string
+	for _, directive := range d.Funcs {
+		funcs = append(funcs, directive.Name)
+	}
+	assert.Equal(t, []string{"parent", "parent"}, funcs)
+
+	_, err = internal.Scan("view.html", []byte(`{{parent}}`), "{{", "}}")
+	assert.Error(t, err)
+}
+
+func TestApply(t *testing.T) {
+	code := []byte("{{- extends \"layout.html\"\n-}}\n{{template \"p.html\"}}")
+
+	d, err := internal.Scan("view.html", code, "{{", "}}")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	out := internal.Apply(code, []internal.Edit{
+		{Pos: d.Includes[0].Pos, End: d.Includes[0].End, Text: `"@main/p.html"`},
+		internal.Blank(code, d.ExtendsPos, d.ExtendsEnd),
+	})
+
+	assert.Equal(t, "\n\n{{template \"@main/p.html\"}}", string(out))
+}
+
+func TestOrigin(t *testing.T) {
+	orig := []byte("{{extends \"l.html\"}}\n<p>{{template \"p.html\"}}{{.X}}</p>")
+	edits := []internal.Edit{
+		{Pos: 0, End: 20, Text: ""},
+		{Pos: 35, End: 43, Text: `"@main/p.html"`},
+	}
+	code := internal.Apply(orig, edits)
+
+	offset := internal.Offset(code, 2, bytes.Index(code, []byte(".X"))-1-bytes.IndexByte(code, '\n'))
+	assert.Equal(t, ".X", string(code[offset:offset+2]))
+
+	line, col := internal.Position(orig, internal.Origin(edits, offset))
+	assert.Equal(t, 2, line)
+	assert.Equal(t, bytes.Index(orig, []byte(".X"))-bytes.IndexByte(orig, '\n')-1, col)
+}
@@ -0,0 +1,313 @@
+package et
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/gowool/extends-template/internal"
+)
+
+// Lint rules
+const (
+	RuleParseError      = "parse-error"
+	RuleMissingTemplate = "missing-template"
+	RuleInvalidName     = "invalid-name"
+	RuleUnusedDefine    = "unused-define"
+	RuleDuplicateDefine = "duplicate-define"
+	RuleUnusedPartial   = "unused-partial"
+	RuleMissingFunc     = "missing-func"
+)
+
+// builtins are the functions predefined by text/template and html/template
+var builtins = []string{
+	"and", "call", "html", "index", "slice", "js", "len", "not", "or", "print", "printf", "println",
+	"urlquery", "eq", "ge", "gt", "le", "lt", "ne",
+}
+
+// LintIssue is a problem found by Lint
+type LintIssue struct {
+	Rule    string `json:"rule"`
+	Name    string `json:"name"`
+	Line    int    `json:"line,omitempty"`
+	Col     int    `json:"col,omitempty"`
+	Message string `json:"message"`
+}
+
+func (i LintIssue) String() string {
+	loc := i.Name
+	if i.Line > 0 {
+		loc = fmt.Sprintf("%s:%d", loc, i.Line)
+	}
+	if i.Col > 0 {
+		loc = fmt.Sprintf("%s:%d", loc, i.Col)
+	}
+	return fmt.Sprintf("%s: %s: %s", loc, i.Rule, i.Message)
+}
+
+// Lint statically checks the templates of env matching any of patterns and their dependencies.
+// Without patterns, it checks the entries Graph finds and reports its orphans. It reports:
+//
+//   - sources which do not parse
+//   - extended and included templates which the loader does not have
+//   - relative template names leaving their namespace root
+//   - templates defined by a child which its layouts never render
+//   - templates defined by more than one global
+//   - templates no matched template depends on, or no template at all without patterns
+//   - functions which are neither builtins nor registered with Environment.Funcs
+//
+// Templates are resolved like TemplateWrapper.Parse does, handlers are not run. The loader
+// must be a Lister.
+func Lint(ctx context.Context, env *Environment, patterns ...string) ([]LintIssue, error) {
+	lister, ok := env.loader.(Lister)
+	if !ok {
+		return nil, fmt.Errorf("lint: loader %s is not a Lister", internal.TypeName(env.loader))
+	}
+
+	entries, orphans, err := env.graphEntries(ctx, lister, patterns...)
+	if err != nil {
+		return nil, err
+	}
+
+	env.mu.RLock()
+	l := &linter{
+		loader:  env.loader,
+		left:    env.left,
+		right:   env.right,
+		global:  slices.Clone(env.global),
+		funcs:   make(map[string]struct{}, len(env.funcMap)),
+		sources: make(map[string]*lintSource),
+		reached: make(map[string]struct{}),
+		issues:  make(map[LintIssue]struct{}),
+	}
+	for name := range env.funcMap {
+		l.funcs[name] = struct{}{}
+	}
+	env.mu.RUnlock()
+
+	for _, name := range entries {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		l.entry(ctx, name)
+	}
+
+	if len(patterns) > 0 {
+		all, err := lister.List(ctx, "")
+		if err != nil {
+			return nil, err
+		}
+
+		for _, name := range all {
+			_, ok := l.reached[name]
+			if _, alias := l.reached["@"+BaseNamespace+"/"+name]; !ok && !alias {
+				l.report(LintIssue{Rule: RuleUnusedPartial, Name: name, Message: "template is not used by any matched template"})
+			}
+		}
+	}
+	for _, name := range orphans {
+		l.report(LintIssue{Rule: RuleUnusedPartial, Name: name, Message: "template is not used by any template"})
+	}
+
+	issues := make([]LintIssue, 0, len(l.issues))
+	for issue := range l.issues {
+		issues = append(issues, issue)
+	}
+	slices.SortFunc(issues, func(a, b LintIssue) int {
+		return cmp.Or(
+			cmp.Compare(a.Name, b.Name),
+			cmp.Compare(a.Line, b.Line),
+			cmp.Compare(a.Col, b.Col),
+			cmp.Compare(a.Rule, b.Rule),
+			cmp.Compare(a.Message, b.Message),
+		)
+	})
+	return issues, nil
+}
+
+type lintSource struct {
+	name string
+	code []byte
+	d    *internal.Directives
+}
+
+type linter struct {
+	loader  Loader
+	left    string
+	right   string
+	global  []string
+	funcs   map[string]struct{}
+	sources map[string]*lintSource
+	reached map[string]struct{}
+	issues  map[LintIssue]struct{}
+}
+
+// lintTree is the state of the resolution of one entry template
+type lintTree struct {
+	r       *resolver
+	state   *parseState
+	used    map[string]struct{}
+	visited map[string]struct{}
+	sources []*lintSource
+}
+
+func (l *linter) entry(ctx context.Context, name string) {
+	t := &lintTree{
+		r:       &resolver{loader: l.loader, ns: namespaceOf(name)},
+		state:   newParseState(),
+		used:    make(map[string]struct{}),
+		visited: make(map[string]struct{}),
+	}
+
+	globalDefines := make(map[string]string)
+	for _, global := range l.global {
+		global = qualify(t.r.ns, global)
+		s := l.visit(ctx, t, global)
+		if s == nil {
+			l.report(LintIssue{Rule: RuleMissingTemplate, Name: global, Message: "global template does not exist"})
+			continue
+		}
+
+		for _, define := range s.d.Defines {
+			if other, ok := globalDefines[define]; ok && other != global {
+				l.report(l.issue(s, RuleDuplicateDefine, int(s.d.Trees[define].Root.Pos),
+					fmt.Sprintf("template %q is already defined by global %q", define, other)))
+			} else {
+				globalDefines[define] = global
+			}
+		}
+	}
+
+	l.visit(ctx, t, name)
+
+	for _, s := range t.sources {
+		if len(s.d.Extends) == 0 || slices.ContainsFunc(s.d.Extends, func(c internal.Directive) bool { return c.Expr != "" }) {
+			continue
+		}
+		for _, define := range s.d.Defines {
+			if _, ok := t.used[define]; !ok {
+				l.report(l.issue(s, RuleUnusedDefine, int(s.d.Trees[define].Root.Pos),
+					fmt.Sprintf("template %q is never rendered by the layouts of %q", define, s.name)))
+			}
+		}
+	}
+}
+
+// visit loads and resolves a template in the order of Node.init: defines first, then the
+// extended layout, then the includes not defined so far. It returns nil if the template
+// does not exist or does not parse.
+func (l *linter) visit(ctx context.Context, t *lintTree, name string) *lintSource {
+	l.reached[name] = struct{}{}
+
+	s := l.source(ctx, name)
+	if s == nil {
+		return nil
+	}
+	if _, ok := t.visited[name]; ok {
+		return s
+	}
+	t.visited[name] = struct{}{}
+	t.sources = append(t.sources, s)
+
+	for _, define := range s.d.Defines {
+		t.state.defined[define] = struct{}{}
+	}
+	for _, directive := range s.d.Templates {
+		t.used[directive.Name] = struct{}{}
+	}
+
+	l.extends(ctx, t, s)
+
+	for _, directive := range s.d.Includes {
+		if _, ok := t.state.defined[directive.Name]; ok {
+			continue
+		}
+		include, err := t.r.name(s.name, directive)
+		if err != nil {
+			l.invalid(s, err)
+		} else if l.visit(ctx, t, include) == nil {
+			l.missing(ctx, s, directive, include)
+		}
+	}
+	return s
+}
+
+// extends visits the layout of the source chosen like by Node.init. Candidates evaluated at
+// render time are skipped, a missing template is reported only if no candidate exists.
+func (l *linter) extends(ctx context.Context, t *lintTree, s *lintSource) {
+	if len(s.d.Extends) == 0 {
+		return
+	}
+
+	name, err := t.r.layout(ctx, s.name, s.d.Extends, t.state)
+	switch {
+	case err != nil:
+		l.invalid(s, err)
+	case name != "" && l.visit(ctx, t, name) == nil:
+		l.missing(ctx, s, s.d.Extends[0], name)
+	}
+}
+
+// invalid reports a reference which cannot be resolved
+func (l *linter) invalid(s *lintSource, err error) {
+	var refErr *refError
+	if errors.As(err, &refErr) {
+		l.report(l.issue(s, RuleInvalidName, refErr.pos, refErr.err.Error()))
+		return
+	}
+	l.report(l.issue(s, RuleMissingTemplate, s.d.ExtendsPos, err.Error()))
+}
+
+// source loads and scans a template once, reporting parse errors and unknown functions
+func (l *linter) source(ctx context.Context, name string) *lintSource {
+	if s, ok := l.sources[name]; ok {
+		return s
+	}
+	l.sources[name] = nil
+
+	source, err := l.loader.Get(ctx, name)
+	if err != nil {
+		return nil
+	}
+
+	d, err := internal.Scan(name, source.Code, l.left, l.right)
+	if err != nil {
+		issue := LintIssue{Rule: RuleParseError, Name: name, Message: err.Error()}
+		if m := reLocation.FindStringSubmatch(issue.Message); m != nil {
+			issue.Line, _ = strconv.Atoi(m[2])
+			issue.Message = issue.Message[len(m[0]):]
+		}
+		l.report(issue)
+		return nil
+	}
+
+	s := &lintSource{name: name, code: source.Code, d: d}
+	l.sources[name] = s
+
+	for _, directive := range d.Funcs {
+		if _, ok := l.funcs[directive.Name]; !ok && !slices.Contains(builtins, directive.Name) {
+			l.report(l.issue(s, RuleMissingFunc, directive.Pos, fmt.Sprintf("function %q is not defined", directive.Name)))
+		}
+	}
+	return s
+}
+
+func (l *linter) missing(ctx context.Context, s *lintSource, directive internal.Directive, name string) {
+	message := fmt.Sprintf("template %q does not exist", name)
+	if ok, _ := l.loader.Exists(ctx, name); ok {
+		message = fmt.Sprintf("template %q does not parse", name)
+	}
+	l.report(l.issue(s, RuleMissingTemplate, directive.Pos, message))
+}
+
+func (l *linter) issue(s *lintSource, rule string, pos int, message string) LintIssue {
+	line, col := internal.Position(s.code, pos)
+	return LintIssue{Rule: rule, Name: s.name, Line: line, Col: col + 1, Message: message}
+}
+
+func (l *linter) report(issue LintIssue) {
+	l.issues[issue] = struct{}{}
+}
@@ -0,0 +1,114 @@
+package et_test
+
+import (
+	"context"
+	"html/template"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	et "github.com/gowool/extends-template"
+)
+
+func TestLint(t *testing.T) {
+	env := et.NewEnvironment(et.NewMemoryLoader(map[string][]byte{
+		"@main/globals/a.html": []byte(`{{define "title"}}a{{end}}`),
+		"@main/globals/b.html": []byte(`{{define "title"}}b{{end}}{{define "footer"}}{{end}}`),
+		"@main/layout.html":    []byte("<body>\n{{block \"content\" .}}{{end}}\n</body>"),
+		"@main/nav.html":       []byte(`<nav>{{upper .}}</nav>`),
+		"@main/unused.html":    []byte(`unused`),
+		"@main/broken.html":    []byte("ok\n{{if}}"),
+		"@main/pages/home.html": []byte("{{extends \"layout.html\"}}\n" +
+			"{{define \"content\"}}{{template \"nav.html\" .}}{{template \"item\"}}{{template \"missing.html\"}}{{end}}\n" +
+			"{{define \"item\"}}{{lower .}}{{end}}\n" +
+			"{{define \"sidebar\"}}sidebar{{end}}"),
+		"@main/pages/about.html": []byte(`{{extends "missing-layout.html"}}{{template "broken.html"}}`),
+		"@main/pages/print.html": []byte(`{{extends "print-layout.html" "amp-layout.html"}}`),
+	})).Global("@main/globals/a.html", "@main/globals/b.html").Funcs(template.FuncMap{
+		"upper": strings.ToUpper,
+	})
+
+	issues, err := et.Lint(context.TODO(), env, "@main/pages/**")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var lines []string
+	for _, issue := range issues {
+		lines = append(lines, issue.String())
+	}
+	assert.Equal(t, []string{
+		`@main/broken.html:2: parse-error: missing value for if`,
+		`@main/globals/b.html:1:19: duplicate-define: template "title" is already defined by global "@main/globals/a.html"`,
+		`@main/pages/about.html:1:11: missing-template: template "@main/missing-layout.html" does not exist`,
+		`@main/pages/about.html:1:45: missing-template: template "@main/broken.html" does not parse`,
+		`@main/pages/home.html:2:76: missing-template: template "@main/missing.html" does not exist`,
+		`@main/pages/home.html:3:20: missing-func: function "lower" is not defined`,
+		`@main/pages/home.html:4:21: unused-define: template "sidebar" is never rendered by the layouts of "@main/pages/home.html"`,
+		`@main/pages/print.html:1:1: missing-template: none of the layouts "@main/print-layout.html", "@main/amp-layout.html" extended by "@main/pages/print.html" exists`,
+		`@main/unused.html: unused-partial: template is not used by any matched template`,
+	}, lines)
+}
+
+func TestLint_AllTemplates(t *testing.T) {
+	env := et.NewEnvironment(et.NewMemoryLoader(map[string][]byte{
+		"layout.html": []byte(`<body>{{block "content" .}}{{end}}</body>`),
+		"home.html":   []byte(`{{extends "mobile.html" "layout.html"}}{{define "content"}}{{len .}}{{end}}`),
+		"modal.html":  []byte(`{{extends .Layout}}{{define "content"}}{{end}}`),
+		"unused.html": []byte(`unused`),
+	}))
+
+	issues, err := et.Lint(context.TODO(), env)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, []et.LintIssue{
+			{Rule: et.RuleUnusedPartial, Name: "unused.html", Message: "template is not used by any template"},
+		}, issues)
+	}
+}
+
+func TestLint_Entries(t *testing.T) {
+	// the partial renders a template defined by the page, it cannot be checked on its own
+	env := et.NewEnvironment(et.NewMemoryLoader(map[string][]byte{
+		"@main/layout.html":        []byte(`<html>{{template "partials/head.html" .}}{{block "content" .}}{{end}}</html>`),
+		"@main/partials/head.html": []byte(`<title>{{template "title" .}}</title>`),
+		"@main/pages/home.html":    []byte(`{{extends "layout.html"}}{{define "title"}}home{{end}}{{define "content"}}home{{end}}`),
+		"@main/amp.html":           []byte(`<amp>{{template "content" .}}</amp>`),
+		"@main/pages/view.html":    []byte(`{{extends .Layout "@main/layout.html"}}{{define "title"}}view{{end}}{{define "content"}}view{{end}}`),
+	}))
+
+	issues, err := et.Lint(context.TODO(), env)
+
+	assert.NoError(t, err)
+	assert.Empty(t, issues)
+}
+
+func TestLint_NotLister(t *testing.T) {
+	_, err := et.Lint(context.TODO(), et.NewEnvironment(wrapLoader{}))
+
+	assert.Error(t, err)
+}
+
+func TestLint_Relative(t *testing.T) {
+	env := et.NewEnvironment(et.NewMemoryLoader(map[string][]byte{
+		"@main/layout.html":      []byte(`<body>{{block "content" .}}{{end}}</body>`),
+		"@main/pages/card.html":  []byte(`<card></card>`),
+		"@main/pages/home.html":  []byte(`{{extends "../layout.html"}}{{define "content"}}{{template "./card.html"}}{{end}}`),
+		"@main/pages/about.html": []byte(`{{template "../../card.html"}}{{template "./missing.html"}}`),
+	}))
+
+	issues, err := et.Lint(context.TODO(), env)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var lines []string
+	for _, issue := range issues {
+		lines = append(lines, issue.String())
+	}
+	assert.Equal(t, []string{
+		`@main/pages/about.html:1:12: invalid-name: invalid template name "../../card.html": escapes the namespace root`,
+		`@main/pages/about.html:1:42: missing-template: template "@main/pages/missing.html" does not exist`,
+	}, lines)
+}
@@ -1,6 +1,13 @@
 package et
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
 const (
 	ErrNotDefinedFormat   = "template \"%s\" is not defined"
@@ -8,18 +15,137 @@ const (
 )
 
 type Source struct {
-	Code []byte
-	Name string
-	File string
+	Code    []byte
+	Name    string
+	File    string
+	ModTime time.Time
+	Version string
 }
 
 type Loader interface {
 	// Get returns a Source for a given template name
 	Get(ctx context.Context, name string) (*Source, error)
 
-	// IsFresh check if template is fresh
+	// IsFresh check if template is fresh, t is the time of the last parse in Unix seconds.
+	// Loaders which are a Versioner are checked by version instead, with sub-second precision.
 	IsFresh(ctx context.Context, name string, t int64) (bool, error)
 
-	// Exists check if template exists
+	// Exists check if template exists, the error of a template which does not exist wraps ErrNotFound
 	Exists(ctx context.Context, name string) (bool, error)
 }
+
+// Notifier is an optional Loader capability for loaders which push change events
+// instead of relying on IsFresh being polled
+type Notifier interface {
+	// Notify registers fn to be called with the names of changed templates, or without names
+	// when any template may have changed. Calling the returned function unregisters fn.
+	Notify(fn func(names ...string)) (unsubscribe func())
+
+	// Notifying reports whether the loader currently notifies about every template it serves,
+	// only then callers may skip IsFresh
+	Notifying() bool
+}
+
+// Versioner is an optional Loader capability reporting the current version of a template,
+// such as a content digest or an etag. The version of the loaded content is returned in
+// Source.Version, and freshness is decided by comparing versions instead of times.
+type Versioner interface {
+	Version(ctx context.Context, name string) (string, error)
+}
+
+// Immutable is an optional Loader capability of loaders whose templates never change, such as
+// those of an embedded file system. Callers may skip IsFresh when Immutable reports true.
+type Immutable interface {
+	Immutable() bool
+}
+
+// Lister is an optional Loader capability enumerating the templates a loader serves
+type Lister interface {
+	// List returns the sorted names of the templates of a namespace, or of all namespaces
+	// if namespace is empty. Templates of the base namespace are listed without a prefix.
+	List(ctx context.Context, namespace string) ([]string, error)
+}
+
+// Name validates and normalises a template name, as every loader does before looking it up.
+// Empty and "." segments are dropped, with or without a namespace. Names which are empty,
+// absolute, contain ".." segments, backslashes or NUL bytes are rejected with an error
+// wrapping ErrInvalidName.
+func Name(name string) (string, error) {
+	switch {
+	case name == "":
+		return "", invalidName(name, "empty name")
+	case strings.ContainsAny(name, "\\\x00"):
+		return "", invalidName(name, "backslash or NUL byte")
+	case name[0] == '/':
+		return "", invalidName(name, "absolute path")
+	}
+
+	prefix, rest := "", name
+	if name[0] == '@' {
+		namespace, shortname, ok := strings.Cut(name[1:], "/")
+		if !ok || namespace == "" || namespace == "." || namespace == ".." {
+			return "", invalidName(name, "invalid namespace")
+		}
+		prefix, rest = "@"+namespace+"/", shortname
+	}
+
+	segments := strings.Split(rest, "/")
+	kept := segments[:0]
+	for _, segment := range segments {
+		switch segment {
+		case "", ".":
+			continue
+		case "..":
+			return "", invalidName(name, "\"..\" segment")
+		}
+		kept = append(kept, segment)
+	}
+
+	if len(kept) == 0 {
+		return "", invalidName(name, "empty name")
+	}
+	return prefix + strings.Join(kept, "/"), nil
+}
+
+// maxMisses bounds the failed lookups a loader remembers. Names may come from requests, so the
+// misses are not bounded by the templates of the loader.
+const maxMisses = 1024
+
+// missCache remembers failed lookups, at most maxMisses of them. Once it is full, further
+// misses are looked up again each time.
+type missCache struct {
+	m sync.Map
+	n atomic.Int64
+}
+
+// load returns the remembered error of a lookup of name, or nil
+func (c *missCache) load(name string) error {
+	if err, ok := c.m.Load(name); ok {
+		return err.(error)
+	}
+	return nil
+}
+
+func (c *missCache) store(name string, err error) {
+	if c.n.Load() >= maxMisses {
+		return
+	}
+	if _, loaded := c.m.LoadOrStore(name, err); !loaded {
+		c.n.Add(1)
+	}
+}
+
+func (c *missCache) delete(name string) {
+	if _, ok := c.m.LoadAndDelete(name); ok {
+		c.n.Add(-1)
+	}
+}
+
+func isImmutable(loader Loader) bool {
+	i, ok := loader.(Immutable)
+	return ok && i.Immutable()
+}
+
+func invalidName(name, reason string) error {
+	return fmt.Errorf("%w %q: %s", ErrInvalidName, name, reason)
+}
@@ -9,11 +9,18 @@ import (
 	"github.com/gowool/extends-template/internal"
 )
 
-var _ Loader = (*ChainLoader)(nil)
+var (
+	_ Loader    = (*ChainLoader)(nil)
+	_ Notifier  = (*ChainLoader)(nil)
+	_ Versioner = (*ChainLoader)(nil)
+	_ Immutable = (*ChainLoader)(nil)
+	_ Lister    = (*ChainLoader)(nil)
+)
 
 type ChainLoader struct {
 	loaders []Loader
 	cache   *sync.Map
+	misses  *missCache
 	mu      sync.RWMutex
 }
 
@@ -21,6 +28,7 @@ func NewChainLoader(loaders ...Loader) *ChainLoader {
 	return &ChainLoader{
 		loaders: loaders,
 		cache:   new(sync.Map),
+		misses:  new(missCache),
 	}
 }
 
@@ -29,7 +37,7 @@ func (l *ChainLoader) Add(loader Loader) *ChainLoader {
 	defer l.mu.Unlock()
 
 	l.loaders = append(l.loaders, loader)
-	l.cache = new(sync.Map)
+	l.cache, l.misses = new(sync.Map), new(missCache)
 
 	return l
 }
@@ -42,6 +50,20 @@ func (l *ChainLoader) Loaders() (loaders []Loader) {
 	return
 }
 
+// Reset drops the cached lookups of the chain and resets every loader of the chain which has
+// a Reset method, like FileSystemLoader
+func (l *ChainLoader) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.cache, l.misses = new(sync.Map), new(missCache)
+	for _, loader := range l.loaders {
+		if r, ok := loader.(interface{ Reset() }); ok {
+			r.Reset()
+		}
+	}
+}
+
 func (l *ChainLoader) Get(ctx context.Context, name string) (*Source, error) {
 	r, err := l.loop(ctx, name, func(loader Loader) (any, error) {
 		return loader.Get(ctx, name)
@@ -62,40 +84,179 @@ func (l *ChainLoader) IsFresh(ctx context.Context, name string, t int64) (bool,
 	return r.(bool), nil
 }
 
+// Version returns the version reported by the first loader defining the template,
+// or an empty version if that loader is not a Versioner
+func (l *ChainLoader) Version(ctx context.Context, name string) (string, error) {
+	r, err := l.loop(ctx, name, func(loader Loader) (any, error) {
+		if v, ok := loader.(Versioner); ok {
+			return v.Version(ctx, name)
+		}
+		return "", nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return r.(string), nil
+}
+
 func (l *ChainLoader) Exists(ctx context.Context, name string) (bool, error) {
-	if r, ok := l.cache.Load(name); ok {
+	l.mu.RLock()
+	cache, misses := l.cache, l.misses
+	l.mu.RUnlock()
+
+	if r, ok := cache.Load(name); ok {
 		return r.(bool), nil
 	}
+	if err := misses.load(name); err != nil {
+		return false, err
+	}
 	r, err := l.loop(ctx, name, func(loader Loader) (any, error) {
 		return loader.Exists(ctx, name)
 	})
 	if err != nil {
-		l.cache.Store(name, false)
+		// only templates which do not exist are remembered, failing loaders are asked again
+		if errors.Is(err, ErrNotFound) {
+			misses.store(name, err)
+		}
 		return false, err
 	}
 
-	l.cache.Store(name, r.(bool))
+	cache.Store(name, r.(bool))
 	return r.(bool), nil
 }
 
-func (l *ChainLoader) loop(ctx context.Context, name string, fn func(loader Loader) (any, error)) (any, error) {
+// List merges the templates of every loader of the chain which is a Lister. A template defined
+// by several loaders is listed once, as only the first of them is ever used. Errors are returned
+// only if no loader could list the namespace.
+func (l *ChainLoader) List(ctx context.Context, namespace string) ([]string, error) {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
 
+	set := make(map[string]struct{})
+	listed := false
+
 	var err error
+	for _, loader := range l.loaders {
+		lister, ok := loader.(Lister)
+		if !ok {
+			continue
+		}
+
+		names, err1 := lister.List(ctx, namespace)
+		if err1 != nil {
+			err = errors.Join(err, fmt.Errorf("[%s]: %w", internal.TypeName(loader), err1))
+			continue
+		}
+
+		listed = true
+		for _, name := range names {
+			set[name] = struct{}{}
+		}
+	}
+
+	if !listed && err != nil {
+		return nil, err
+	}
+	return sortedNames(set), nil
+}
+
+// Immutable reports whether all loaders of the chain are Immutable
+func (l *ChainLoader) Immutable() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for _, loader := range l.loaders {
+		if !isImmutable(loader) {
+			return false
+		}
+	}
+	return len(l.loaders) > 0
+}
+
+// Notify subscribes fn to every loader of the chain which is a Notifier
+func (l *ChainLoader) Notify(fn func(names ...string)) func() {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var unsubscribes []func()
+	for _, loader := range l.loaders {
+		n, ok := loader.(Notifier)
+		if !ok {
+			continue
+		}
+
+		unsubscribes = append(unsubscribes, n.Notify(func(names ...string) {
+			if len(names) == 0 {
+				l.mu.Lock()
+				l.cache, l.misses = new(sync.Map), new(missCache)
+				l.mu.Unlock()
+			} else {
+				l.mu.RLock()
+				cache, misses := l.cache, l.misses
+				l.mu.RUnlock()
+
+				for _, name := range names {
+					cache.Delete(name)
+					misses.delete(name)
+				}
+			}
+			fn(names...)
+		}))
+	}
+
+	return func() {
+		for _, unsubscribe := range unsubscribes {
+			unsubscribe()
+		}
+	}
+}
+
+// Notifying reports true only if every loader of the chain is Immutable or currently notifying
+func (l *ChainLoader) Notifying() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for _, loader := range l.loaders {
+		if isImmutable(loader) {
+			continue
+		}
+		if n, ok := loader.(Notifier); !ok || !n.Notifying() {
+			return false
+		}
+	}
+	return len(l.loaders) > 0
+}
+
+func (l *ChainLoader) loop(ctx context.Context, name string, fn func(loader Loader) (any, error)) (any, error) {
+	if _, err := Name(name); err != nil {
+		return nil, err
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	// the template is not found only if no loader failed otherwise
+	var err, failed error
 
 	for _, loader := range l.loaders {
 		if ok, err1 := loader.Exists(ctx, name); !ok {
-			err = errors.Join(err, err1)
+			if err1 != nil && !errors.Is(err1, ErrNotFound) {
+				failed = errors.Join(failed, err1)
+			} else {
+				err = errors.Join(err, err1)
+			}
 			continue
 		}
 
 		if r, err1 := fn(loader); err1 == nil {
 			return r, nil
 		} else {
-			err = errors.Join(err, fmt.Errorf("[%s]: %w", internal.TypeName(loader), err1))
+			failed = errors.Join(failed, fmt.Errorf("[%s]: %w", internal.TypeName(loader), err1))
 		}
 	}
 
-	return nil, errors.Join(fmt.Errorf(ErrNotDefinedFormat, name), err)
+	if failed != nil {
+		return nil, errors.Join(fmt.Errorf(ErrNotDefinedFormat, name), failed)
+	}
+	return nil, notFound(errors.Join(fmt.Errorf(ErrNotDefinedFormat, name), err))
 }
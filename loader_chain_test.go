@@ -3,7 +3,10 @@ package et_test
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sync/atomic"
 	"testing"
+	"testing/fstest"
 
 	"github.com/stretchr/testify/assert"
 
@@ -127,6 +130,65 @@ func TestChainLoader_Exists(t *testing.T) {
 	}
 }
 
+type downLoader struct {
+	loader1
+}
+
+func (downLoader) Exists(context.Context, string) (bool, error) {
+	return false, errors.New("loader is down")
+}
+
+func TestChainLoader_NotFound(t *testing.T) {
+	_, err := et.NewChainLoader(et.NewMemoryLoader(nil), loader1{}).Exists(context.TODO(), "no-file.html")
+	assert.ErrorIs(t, err, et.ErrNotFound)
+
+	_, err = et.NewChainLoader(et.NewMemoryLoader(nil), downLoader{}).Exists(context.TODO(), "no-file.html")
+	assert.ErrorContains(t, err, "loader is down")
+	assert.NotErrorIs(t, err, et.ErrNotFound)
+}
+
+type existsLoader struct {
+	et.Loader
+	calls atomic.Int32
+	err   error
+}
+
+func (l *existsLoader) Exists(ctx context.Context, name string) (bool, error) {
+	l.calls.Add(1)
+	if l.err != nil {
+		return false, l.err
+	}
+	return l.Loader.Exists(ctx, name)
+}
+
+func TestChainLoader_Misses(t *testing.T) {
+	inner := &existsLoader{Loader: et.NewMemoryLoader(nil)}
+	loader := et.NewChainLoader(inner)
+
+	// names of requests are not bounded, neither are the misses remembered
+	names := make([]string, 5000)
+	for i := range names {
+		names[i] = fmt.Sprintf("missing%d.html", i)
+	}
+	for range 2 {
+		for _, name := range names {
+			_, err := loader.Exists(context.TODO(), name)
+			assert.ErrorIs(t, err, et.ErrNotFound)
+		}
+	}
+	assert.Greater(t, int(inner.calls.Load()), len(names)+1)
+	assert.Less(t, int(inner.calls.Load()), 2*len(names))
+
+	// failures other than missing templates are not remembered
+	down := &existsLoader{Loader: et.NewMemoryLoader(nil), err: errors.New("loader is down")}
+	loader = et.NewChainLoader(down)
+	for range 2 {
+		_, err := loader.Exists(context.TODO(), "view.html")
+		assert.ErrorContains(t, err, "loader is down")
+	}
+	assert.Equal(t, int32(2), down.calls.Load())
+}
+
 type loader1 struct{}
 
 func (loader1) Get(_ context.Context, name string) (*et.Source, error) {
@@ -160,3 +222,55 @@ func (loader2) IsFresh(_ context.Context, name string, _ int64) (bool, error) {
 func (loader2) Exists(_ context.Context, name string) (bool, error) {
 	return name == f2, nil
 }
+
+func TestChainLoader_List(t *testing.T) {
+	loader := et.NewChainLoader(
+		loader1{},
+		et.NewMemoryLoader(map[string][]byte{"file.html": nil, "@main/file.html": nil}),
+		et.NewMemoryLoader(map[string][]byte{"file.html": nil, "other.html": nil}),
+	)
+
+	names, err := loader.List(context.TODO(), "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"@main/file.html", "file.html", "other.html"}, names)
+
+	fsLoader := newFilesystemLoader()
+	_ = fsLoader.BaseAppend("base")
+	loader = et.NewChainLoader(fsLoader)
+
+	_, err = loader.List(context.TODO(), "main")
+	assert.Error(t, err)
+
+	names, err = et.NewChainLoader(fsLoader, et.NewMemoryLoader(map[string][]byte{"@main/file.html": nil})).List(context.TODO(), "main")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"@main/file.html"}, names)
+}
+
+func TestChainLoader_Reset(t *testing.T) {
+	fsys := fstest.MapFS{"base/view.html": &fstest.MapFile{Data: []byte("view")}}
+	fsLoader := et.NewFileSystemLoader(fsys)
+	_ = fsLoader.BaseAppend("base")
+	loader := et.NewChainLoader(loader1{}, fsLoader)
+
+	exists, _ := loader.Exists(context.TODO(), "new.html")
+	assert.False(t, exists)
+
+	fsys["base/new.html"] = &fstest.MapFile{Data: []byte("new")}
+
+	exists, _ = loader.Exists(context.TODO(), "new.html")
+	assert.False(t, exists)
+
+	loader.Reset()
+
+	exists, err := loader.Exists(context.TODO(), "new.html")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestChainLoader_Immutable(t *testing.T) {
+	embedLoader, _ := et.NewEmbedLoader(embedFS)
+
+	assert.True(t, et.NewChainLoader(embedLoader).Immutable())
+	assert.False(t, et.NewChainLoader(embedLoader, et.NewMemoryLoader(nil)).Immutable())
+	assert.False(t, et.NewChainLoader().Immutable())
+}
@@ -0,0 +1,144 @@
+package et
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+
+	"github.com/gowool/extends-template/internal"
+)
+
+var (
+	_ Loader    = (*EmbedLoader)(nil)
+	_ Immutable = (*EmbedLoader)(nil)
+	_ Versioner = (*EmbedLoader)(nil)
+	_ Lister    = (*EmbedLoader)(nil)
+)
+
+type embedTemplate struct {
+	code    []byte
+	file    string
+	version string
+}
+
+// EmbedLoader serves templates of a file system which never changes, such as embed.FS.
+// Every top-level directory is a namespace, like with NewFSLoaderWithNS, and all files
+// are indexed and read once on creation. Templates are always fresh, and the loader
+// is Immutable, so an Environment never checks their freshness.
+type EmbedLoader struct {
+	templates map[string]embedTemplate
+	modTime   time.Time
+}
+
+func NewEmbedLoader(fsys fs.FS) (*EmbedLoader, error) {
+	if fsys == nil {
+		panic("fs.FS is nil")
+	}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	l := &EmbedLoader{templates: make(map[string]embedTemplate)}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		namespace := entry.Name()
+		if err = fs.WalkDir(fsys, namespace, func(file string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+
+			code, err := fs.ReadFile(fsys, file)
+			if err != nil {
+				return err
+			}
+
+			t := embedTemplate{code: code, file: file, version: internal.Hash(code)}
+			shortname := file[len(namespace)+1:]
+
+			l.templates["@"+namespace+"/"+shortname] = t
+			if namespace == BaseNamespace {
+				l.templates[shortname] = t
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// Stamp replaces the content digests with a version and a modification time set at build time,
+// e.g. a release tag and a commit date passed with -ldflags. It must be called before the
+// loader is used.
+func (l *EmbedLoader) Stamp(version string, modTime time.Time) *EmbedLoader {
+	for name, t := range l.templates {
+		t.version = version
+		l.templates[name] = t
+	}
+	l.modTime = modTime
+
+	return l
+}
+
+func (l *EmbedLoader) Get(_ context.Context, name string) (*Source, error) {
+	t, err := l.template(name)
+	if err != nil {
+		return nil, err
+	}
+	return &Source{Code: t.code, Name: name, File: t.file, ModTime: l.modTime, Version: t.version}, nil
+}
+
+// IsFresh reports true for every existing template, embedded files never change
+func (l *EmbedLoader) IsFresh(ctx context.Context, name string, _ int64) (bool, error) {
+	return l.Exists(ctx, name)
+}
+
+func (l *EmbedLoader) Exists(_ context.Context, name string) (bool, error) {
+	if _, err := l.template(name); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (l *EmbedLoader) Version(_ context.Context, name string) (string, error) {
+	t, err := l.template(name)
+	if err != nil {
+		return "", err
+	}
+	return t.version, nil
+}
+
+// List returns the names of the embedded templates, base templates are listed without a prefix
+func (l *EmbedLoader) List(_ context.Context, namespace string) ([]string, error) {
+	set := make(map[string]struct{})
+	for name := range l.templates {
+		if !strings.HasPrefix(name, "@"+BaseNamespace+"/") && inNamespace(name, namespace) {
+			set[name] = struct{}{}
+		}
+	}
+	return sortedNames(set), nil
+}
+
+// Immutable reports true, embedded templates never change
+func (l *EmbedLoader) Immutable() bool {
+	return true
+}
+
+func (l *EmbedLoader) template(name string) (embedTemplate, error) {
+	normalized, err := Name(name)
+	if err != nil {
+		return embedTemplate{}, err
+	}
+
+	if t, ok := l.templates[normalized]; ok {
+		return t, nil
+	}
+	return embedTemplate{}, notFound(fmt.Errorf(ErrNotDefinedFormat, name))
+}
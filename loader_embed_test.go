@@ -0,0 +1,107 @@
+package et_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	et "github.com/gowool/extends-template"
+)
+
+var embedFS = fstest.MapFS{
+	"base/layout.html":     &fstest.MapFile{Data: []byte(`<main>{{block "content" .}}{{end}}</main>`)},
+	"main/views/home.html": &fstest.MapFile{Data: []byte(`{{extends "@base/layout.html"}}{{define "content"}}home{{end}}`)},
+	"main/views/menu.html": &fstest.MapFile{Data: []byte(`menu`)},
+	"README.md":            &fstest.MapFile{Data: []byte(`readme`)},
+}
+
+func TestEmbedLoader_Get(t *testing.T) {
+	loader, err := et.NewEmbedLoader(embedFS)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	scenarios := []struct {
+		view    string
+		file    string
+		isError bool
+	}{
+		{view: "layout.html", file: "base/layout.html"},
+		{view: "@base/layout.html", file: "base/layout.html"},
+		{view: "@main/views/home.html", file: "main/views/home.html"},
+		{view: "views/home.html", isError: true},
+		{view: "README.md", isError: true},
+		{view: "@main/README.md", isError: true},
+	}
+
+	for _, s := range scenarios {
+		source, err := loader.Get(context.TODO(), s.view)
+
+		if s.isError {
+			assert.Nil(t, source)
+			assert.Error(t, err)
+		} else if assert.NoError(t, err) {
+			assert.Equal(t, s.view, source.Name)
+			assert.Equal(t, s.file, source.File)
+			assert.Equal(t, embedFS[s.file].Data, source.Code)
+			assert.True(t, source.ModTime.IsZero())
+			assert.NotEmpty(t, source.Version)
+		}
+	}
+}
+
+func TestEmbedLoader_IsFresh(t *testing.T) {
+	loader, _ := et.NewEmbedLoader(embedFS)
+
+	isFresh, err := loader.IsFresh(context.TODO(), "layout.html", 0)
+	assert.NoError(t, err)
+	assert.True(t, isFresh)
+
+	isFresh, err = loader.IsFresh(context.TODO(), "no-file.html", 0)
+	assert.Error(t, err)
+	assert.False(t, isFresh)
+}
+
+func TestEmbedLoader_Stamp(t *testing.T) {
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	loader, _ := et.NewEmbedLoader(embedFS)
+	loader.Stamp("v1.2.3", modTime)
+
+	source, err := loader.Get(context.TODO(), "@main/views/menu.html")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "v1.2.3", source.Version)
+		assert.Equal(t, modTime, source.ModTime)
+	}
+
+	version, err := loader.Version(context.TODO(), "layout.html")
+	assert.NoError(t, err)
+	assert.Equal(t, "v1.2.3", version)
+}
+
+func TestEmbedLoader_Environment(t *testing.T) {
+	loader, _ := et.NewEmbedLoader(embedFS)
+	assert.True(t, loader.Immutable())
+
+	env := et.NewEnvironment(loader)
+
+	var out bytes.Buffer
+	if assert.NoError(t, env.Render(context.TODO(), &out, "@main/views/home.html", nil)) {
+		assert.Equal(t, "<main>home</main>", out.String())
+	}
+}
+
+func TestEmbedLoader_List(t *testing.T) {
+	loader, _ := et.NewEmbedLoader(embedFS)
+
+	names, err := loader.List(context.TODO(), "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"@main/views/home.html", "@main/views/menu.html", "layout.html"}, names)
+
+	names, err = loader.List(context.TODO(), "main")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"@main/views/home.html", "@main/views/menu.html"}, names)
+}
@@ -9,9 +9,16 @@ import (
 	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
+
+	"github.com/gowool/extends-template/internal"
 )
 
-var _ Loader = (*FileSystemLoader)(nil)
+var (
+	_ Loader    = (*FileSystemLoader)(nil)
+	_ Versioner = (*FileSystemLoader)(nil)
+	_ Lister    = (*FileSystemLoader)(nil)
+)
 
 const BaseNamespace = "base"
 
@@ -20,9 +27,10 @@ var sep = fmt.Sprintf("%c", filepath.Separator)
 type FileSystemLoader struct {
 	fsys   fs.FS
 	paths  *sync.Map
-	errors *sync.Map
+	errors *missCache
 	cache  *sync.Map
-	mu     sync.Mutex
+	digest atomic.Bool
+	mu     sync.RWMutex
 }
 
 func NewFSLoaderWithNS(fsys fs.FS) (*FileSystemLoader, error) {
@@ -50,14 +58,22 @@ func NewFileSystemLoader(fsys fs.FS) *FileSystemLoader {
 	return &FileSystemLoader{
 		fsys:   fsys,
 		paths:  new(sync.Map),
-		errors: new(sync.Map),
+		errors: new(missCache),
 		cache:  new(sync.Map),
 	}
 }
 
+// Digest switches versions from modification time and size to a digest of the file content.
+// Digests catch edits which keep the modification time, at the cost of reading every
+// dependency on each freshness check.
+func (l *FileSystemLoader) Digest(digest bool) *FileSystemLoader {
+	l.digest.Store(digest)
+	return l
+}
+
 func (l *FileSystemLoader) Namespaces() (namespaces []string) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
+	l.mu.RLock()
+	defer l.mu.RUnlock()
 
 	l.paths.Range(func(key, _ any) bool {
 		namespaces = append(namespaces, key.(string))
@@ -67,8 +83,8 @@ func (l *FileSystemLoader) Namespaces() (namespaces []string) {
 }
 
 func (l *FileSystemLoader) Paths(namespace string) (paths []string) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
+	l.mu.RLock()
+	defer l.mu.RUnlock()
 
 	if data, ok := l.paths.Load(namespace); ok {
 		paths = make([]string, len(data.([]string)))
@@ -130,25 +146,38 @@ func (l *FileSystemLoader) SetPaths(namespace string, paths ...string) error {
 }
 
 func (l *FileSystemLoader) Get(_ context.Context, name string) (*Source, error) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
+	l.mu.RLock()
+	defer l.mu.RUnlock()
 
 	file, err := l.find(name)
 	if err != nil {
 		return nil, err
 	}
 
+	stat, err := fs.Stat(l.fsys, file)
+	if err != nil {
+		return nil, err
+	}
+
 	code, err := fs.ReadFile(l.fsys, file)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Source{Name: name, Code: code, File: file}, nil
+	source := &Source{Name: name, Code: code, File: file, ModTime: stat.ModTime()}
+	if l.digest.Load() {
+		source.Version = internal.Hash(code)
+	} else {
+		source.Version = statVersion(stat)
+	}
+	return source, nil
 }
 
+// IsFresh compares the file modification time with t in seconds. Files without
+// a modification time, like those of embed.FS, never change and are always fresh.
 func (l *FileSystemLoader) IsFresh(_ context.Context, name string, t int64) (bool, error) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
+	l.mu.RLock()
+	defer l.mu.RUnlock()
 
 	file, err := l.find(name)
 	if err != nil {
@@ -157,31 +186,112 @@ func (l *FileSystemLoader) IsFresh(_ context.Context, name string, t int64) (boo
 
 	if stat, err := fs.Stat(l.fsys, file); err != nil {
 		return false, err
+	} else if stat.ModTime().IsZero() {
+		return true, nil
 	} else {
 		return stat.ModTime().Unix() < t, nil
 	}
 }
 
+func (l *FileSystemLoader) Version(_ context.Context, name string) (string, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	file, err := l.find(name)
+	if err != nil {
+		return "", err
+	}
+
+	if l.digest.Load() {
+		code, err := fs.ReadFile(l.fsys, file)
+		if err != nil {
+			return "", err
+		}
+		return internal.Hash(code), nil
+	}
+
+	stat, err := fs.Stat(l.fsys, file)
+	if err != nil {
+		return "", err
+	}
+	return statVersion(stat), nil
+}
+
 func (l *FileSystemLoader) Exists(_ context.Context, name string) (bool, error) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
+	l.mu.RLock()
+	defer l.mu.RUnlock()
 
 	_, err := l.find(name)
 	return err == nil, err
 }
 
+// List walks the paths of a namespace, or of all namespaces if namespace is empty.
+// A file shadowed by a file with the same name in a preceding path is listed once.
+func (l *FileSystemLoader) List(ctx context.Context, namespace string) ([]string, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if namespace != "" {
+		if _, ok := l.paths.Load(namespace); !ok {
+			return nil, fmt.Errorf("there are no registered paths for namespace \"%s\"", namespace)
+		}
+	}
+
+	set := make(map[string]struct{})
+
+	var err error
+	l.paths.Range(func(key, value any) bool {
+		ns := key.(string)
+		if namespace != "" && ns != namespace {
+			return true
+		}
+
+		for _, p := range value.([]string) {
+			root := filepath.ToSlash(p)
+			if err = fs.WalkDir(l.fsys, root, func(file string, d fs.DirEntry, err error) error {
+				if err != nil || d.IsDir() {
+					return err
+				}
+				if err = ctx.Err(); err != nil {
+					return err
+				}
+
+				shortname := strings.TrimPrefix(file, root+"/")
+				if ns == BaseNamespace {
+					set[shortname] = struct{}{}
+				} else {
+					set["@"+ns+"/"+shortname] = struct{}{}
+				}
+				return nil
+			}); err != nil {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sortedNames(set), nil
+}
+
 func (l *FileSystemLoader) find(name string) (string, error) {
 	if p, ok := l.cache.Load(name); ok {
 		return p.(string), nil
 	}
 
-	if err, ok := l.errors.Load(name); ok {
-		return "", err.(error)
+	if err := l.errors.load(name); err != nil {
+		return "", err
 	}
 
-	namespace, shortname := l.parse(name)
+	normalized, err := Name(name)
+	if err != nil {
+		return "", err
+	}
+
+	namespace, shortname := l.parse(normalized)
 
-	var err error
 	if paths, ok := l.paths.Load(namespace); ok {
 		for _, p := range paths.([]string) {
 			file := filepath.Join(p, shortname)
@@ -190,16 +300,24 @@ func (l *FileSystemLoader) find(name string) (string, error) {
 				return file, nil
 			}
 		}
-		err = fmt.Errorf("unable to find template \"%s\" (looked into: %s)", name, strings.Join(paths.([]string), ", "))
+		err = notFound(fmt.Errorf("unable to find template \"%s\" (looked into: %s)", name, strings.Join(paths.([]string), ", ")))
 	} else {
-		err = fmt.Errorf("there are no registered paths for namespace \"%s\"", namespace)
+		err = notFound(fmt.Errorf("there are no registered paths for namespace \"%s\"", namespace))
 	}
 
-	l.errors.Store(name, err)
+	l.errors.store(name, err)
 
 	return "", err
 }
 
+func statVersion(stat fs.FileInfo) string {
+	var modTime int64
+	if !stat.ModTime().IsZero() {
+		modTime = stat.ModTime().UnixNano()
+	}
+	return fmt.Sprintf("%x-%x", modTime, stat.Size())
+}
+
 func (l *FileSystemLoader) add(namespace, p string) (err error) {
 	if p, err = l.path(p); err != nil {
 		return
@@ -237,7 +355,50 @@ func (l *FileSystemLoader) parse(name string) (string, string) {
 	return BaseNamespace, name
 }
 
+// names returns the template names which may resolve to the given slash-separated files
+func (l *FileSystemLoader) names(files ...string) (names []string) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	l.paths.Range(func(key, value any) bool {
+		namespace := key.(string)
+		for _, p := range value.([]string) {
+			prefix := filepath.ToSlash(p) + "/"
+			for _, file := range files {
+				if shortname, ok := strings.CutPrefix(file, prefix); ok {
+					names = append(names, "@"+namespace+"/"+shortname)
+					if namespace == BaseNamespace {
+						names = append(names, shortname)
+					}
+				}
+			}
+		}
+		return true
+	})
+	return
+}
+
+// forget drops the cached lookups of names
+func (l *FileSystemLoader) forget(names ...string) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for _, name := range names {
+		l.cache.Delete(name)
+		l.errors.delete(name)
+	}
+}
+
+// Reset drops the cached lookups of template files, so that files added or removed
+// since are found
+func (l *FileSystemLoader) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.reset()
+}
+
 func (l *FileSystemLoader) reset() {
-	l.errors = new(sync.Map)
+	l.errors = new(missCache)
 	l.cache = new(sync.Map)
 }
@@ -2,8 +2,11 @@ package et_test
 
 import (
 	"context"
+	"io/fs"
 	"os"
+	"path"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/stretchr/testify/assert"
@@ -177,3 +180,119 @@ func TestFilesystemLoader_IsFresh(t *testing.T) {
 		}
 	}
 }
+
+func TestFilesystemLoader_Version(t *testing.T) {
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	fsys := fstest.MapFS{
+		"base/view.html": &fstest.MapFile{Data: []byte("view"), ModTime: modTime},
+	}
+
+	for _, digest := range []bool{false, true} {
+		loader := et.NewFileSystemLoader(fsys).Digest(digest)
+		_ = loader.BaseAppend("base")
+
+		source, err := loader.Get(context.TODO(), "view.html")
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		version, err := loader.Version(context.TODO(), "view.html")
+		if assert.NoError(t, err) {
+			assert.Equal(t, source.Version, version)
+		}
+
+		// sub-second modification time change with the same content
+		fsys["base/view.html"].ModTime = modTime.Add(time.Millisecond)
+		version, _ = loader.Version(context.TODO(), "view.html")
+		assert.Equal(t, digest, source.Version == version)
+
+		// content change keeping the modification time and the size
+		fsys["base/view.html"].Data = []byte("VIEW")
+		fsys["base/view.html"].ModTime = modTime
+		version, _ = loader.Version(context.TODO(), "view.html")
+		assert.Equal(t, !digest, source.Version == version)
+
+		fsys["base/view.html"].Data = []byte("view")
+	}
+}
+
+func TestFilesystemLoader_IsFreshZeroModTime(t *testing.T) {
+	loader := et.NewFileSystemLoader(fstest.MapFS{
+		"base/view.html": &fstest.MapFile{Data: []byte("view")},
+	})
+	_ = loader.BaseAppend("base")
+
+	isFresh, err := loader.IsFresh(context.TODO(), "view.html", 0)
+
+	assert.NoError(t, err)
+	assert.True(t, isFresh)
+}
+
+func TestFilesystemLoader_List(t *testing.T) {
+	loader := newFilesystemLoader()
+	_ = loader.SetPaths("base", "main", "base")
+	_ = loader.SetPaths("main", "main")
+
+	names, err := loader.List(context.TODO(), "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"@main/views/home.html", "layout.html", "views/home.html"}, names)
+
+	names, err = loader.List(context.TODO(), "main")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"@main/views/home.html"}, names)
+
+	_, err = loader.List(context.TODO(), "admin")
+	assert.Error(t, err)
+}
+
+func TestFilesystemLoader_Reset(t *testing.T) {
+	fsys := fstest.MapFS{"base/view.html": &fstest.MapFile{Data: []byte("view")}}
+	loader := et.NewFileSystemLoader(fsys)
+	_ = loader.BaseAppend("base")
+
+	exists, _ := loader.Exists(context.TODO(), "new.html")
+	assert.False(t, exists)
+
+	fsys["base/new.html"] = &fstest.MapFile{Data: []byte("new")}
+
+	exists, _ = loader.Exists(context.TODO(), "new.html")
+	assert.False(t, exists)
+
+	loader.Reset()
+
+	exists, err := loader.Exists(context.TODO(), "new.html")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+}
+
+// permissiveFS opens any path it can clean, unlike the fs.FS implementations of the standard library
+type permissiveFS struct {
+	fsys fs.FS
+}
+
+func (f permissiveFS) Open(name string) (fs.File, error) {
+	return f.fsys.Open(path.Clean(name))
+}
+
+func TestFilesystemLoader_InvalidName(t *testing.T) {
+	loader := et.NewFileSystemLoader(permissiveFS{fstest.MapFS{
+		"main/home.html": {Data: []byte("home")},
+		"secret.html":    {Data: []byte("secret")},
+	}})
+	_ = loader.SetPaths("main", "main")
+
+	source, err := loader.Get(context.TODO(), "@main/../secret.html")
+	assert.Nil(t, source)
+	assert.ErrorIs(t, err, et.ErrInvalidName)
+
+	ok, err := loader.Exists(context.TODO(), "@main/../secret.html")
+	assert.False(t, ok)
+	assert.ErrorIs(t, err, et.ErrInvalidName)
+
+	_, err = loader.Version(context.TODO(), `@main/..\secret.html`)
+	assert.ErrorIs(t, err, et.ErrInvalidName)
+
+	if source, err = loader.Get(context.TODO(), "@main/./home.html"); assert.NoError(t, err) {
+		assert.Equal(t, "home", string(source.Code))
+	}
+}
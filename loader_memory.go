@@ -4,32 +4,66 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
+
+	"github.com/gowool/extends-template/internal"
 )
 
-var _ Loader = (*MemoryLoader)(nil)
+var (
+	_ Loader    = (*MemoryLoader)(nil)
+	_ Versioner = (*MemoryLoader)(nil)
+	_ Lister    = (*MemoryLoader)(nil)
+)
+
+type memoryTemplate struct {
+	code    []byte
+	modTime time.Time
+	version string
+}
 
 type MemoryLoader struct {
 	templates *sync.Map
 }
 
 func NewMemoryLoader(templates map[string][]byte) *MemoryLoader {
-	t := new(sync.Map)
+	l := &MemoryLoader{templates: new(sync.Map)}
 	for name, code := range templates {
-		t.Store(name, code)
+		l.Add(name, code)
 	}
-	return &MemoryLoader{templates: t}
+	return l
 }
 
+// Add stores a template under its normalised name. Templates with an invalid name could never
+// be looked up and are skipped, use Store to get the error.
 func (l *MemoryLoader) Add(name string, code []byte) *MemoryLoader {
-	l.templates.Store(name, code)
+	_ = l.Store(name, code)
 	return l
 }
 
+// Store stores a template under its normalised name, or returns an error wrapping ErrInvalidName
+func (l *MemoryLoader) Store(name string, code []byte) error {
+	name, err := Name(name)
+	if err != nil {
+		return err
+	}
+	l.templates.Store(name, memoryTemplate{code: code, modTime: time.Now(), version: internal.Hash(code)})
+	return nil
+}
+
 func (l *MemoryLoader) Get(_ context.Context, name string) (*Source, error) {
-	if code, ok := l.templates.Load(name); ok {
-		return &Source{Code: code.([]byte), Name: name}, nil
+	t, err := l.template(name)
+	if err != nil {
+		return nil, err
+	}
+	return &Source{Code: t.code, Name: name, ModTime: t.modTime, Version: t.version}, nil
+}
+
+func (l *MemoryLoader) Version(_ context.Context, name string) (string, error) {
+	t, err := l.template(name)
+	if err != nil {
+		return "", err
 	}
-	return nil, fmt.Errorf(ErrNotDefinedFormat, name)
+	return t.version, nil
 }
 
 func (l *MemoryLoader) IsFresh(ctx context.Context, name string, _ int64) (bool, error) {
@@ -37,8 +71,31 @@ func (l *MemoryLoader) IsFresh(ctx context.Context, name string, _ int64) (bool,
 }
 
 func (l *MemoryLoader) Exists(_ context.Context, name string) (bool, error) {
-	if _, ok := l.templates.Load(name); ok {
-		return true, nil
+	if _, err := l.template(name); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (l *MemoryLoader) List(_ context.Context, namespace string) ([]string, error) {
+	set := make(map[string]struct{})
+	l.templates.Range(func(key, _ any) bool {
+		if name := key.(string); inNamespace(name, namespace) {
+			set[name] = struct{}{}
+		}
+		return true
+	})
+	return sortedNames(set), nil
+}
+
+func (l *MemoryLoader) template(name string) (memoryTemplate, error) {
+	normalized, err := Name(name)
+	if err != nil {
+		return memoryTemplate{}, err
+	}
+
+	if v, ok := l.templates.Load(normalized); ok {
+		return v.(memoryTemplate), nil
 	}
-	return false, fmt.Errorf(ErrNotDefinedFormat, name)
+	return memoryTemplate{}, notFound(fmt.Errorf(ErrNotDefinedFormat, name))
 }
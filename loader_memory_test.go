@@ -117,3 +117,45 @@ func TestMemoryLoader_Exists(t *testing.T) {
 		}
 	}
 }
+
+func TestMemoryLoader_List(t *testing.T) {
+	loader := et.NewMemoryLoader(map[string][]byte{
+		"file.html":         nil,
+		"@main/file.html":   nil,
+		"@admin/file.html":  nil,
+		"views/file.html":   nil,
+		"@main/a/file.html": nil,
+	})
+
+	names, err := loader.List(context.TODO(), "main")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"@main/a/file.html", "@main/file.html"}, names)
+
+	names, err = loader.List(context.TODO(), et.BaseNamespace)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"file.html", "views/file.html"}, names)
+
+	names, err = loader.List(context.TODO(), "")
+	assert.NoError(t, err)
+	assert.Len(t, names, 5)
+}
+
+func TestMemoryLoader_InvalidName(t *testing.T) {
+	loader := et.NewMemoryLoader(map[string][]byte{"@main//pages//./home.html": []byte("home")})
+
+	ok, err := loader.Exists(context.TODO(), "@main/pages/home.html")
+	assert.True(t, ok)
+	assert.NoError(t, err)
+
+	_, err = loader.Get(context.TODO(), "@main/pages/../pages/home.html")
+	assert.ErrorIs(t, err, et.ErrInvalidName)
+
+	assert.ErrorIs(t, loader.Store("../home.html", []byte("home")), et.ErrInvalidName)
+
+	loader = et.NewMemoryLoader(map[string][]byte{"/x.html": []byte("x"), "y.html": []byte("y")})
+
+	names, err := loader.List(context.TODO(), "")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"y.html"}, names)
+	}
+}
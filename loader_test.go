@@ -0,0 +1,43 @@
+package et_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	et "github.com/gowool/extends-template"
+)
+
+func TestName(t *testing.T) {
+	scenarios := []struct {
+		name     string
+		expected string
+	}{
+		{"home.html", "home.html"},
+		{"pages//home.html", "pages/home.html"},
+		{"./pages/./home.html/", "pages/home.html"},
+		{"@main/pages/home.html", "@main/pages/home.html"},
+		{"@main//pages/home.html", "@main/pages/home.html"},
+		{"@main/./home.html", "@main/home.html"},
+		{"", ""},
+		{".", ""},
+		{"/etc/passwd", ""},
+		{"../home.html", ""},
+		{"pages/../home.html", ""},
+		{"@main/../../secrets/x", ""},
+		{"@../x", ""},
+		{"@/x", ""},
+		{"@main", ""},
+		{`pages\home.html`, ""},
+		{"home.html\x00.txt", ""},
+	}
+
+	for _, s := range scenarios {
+		name, err := et.Name(s.name)
+		if s.expected == "" {
+			assert.ErrorIs(t, err, et.ErrInvalidName, s.name)
+		} else if assert.NoError(t, err, s.name) {
+			assert.Equal(t, s.expected, name)
+		}
+	}
+}
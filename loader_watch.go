@@ -0,0 +1,97 @@
+package et
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	_ Loader   = (*WatchLoader)(nil)
+	_ Notifier = (*WatchLoader)(nil)
+)
+
+// WatchLoader is a FileSystemLoader which is kept up to date by a Watcher. Changed files
+// are translated to template names, dropped from the loader lookup caches and pushed
+// to subscribers, so an Environment can invalidate exactly the affected templates
+// instead of checking every dependency on each load.
+type WatchLoader struct {
+	*FileSystemLoader
+	watcher     Watcher
+	subscribers map[uint64]func(names ...string)
+	next        uint64
+	running     atomic.Int32
+	mu          sync.RWMutex
+}
+
+func NewWatchLoader(loader *FileSystemLoader, watcher Watcher) *WatchLoader {
+	return &WatchLoader{FileSystemLoader: loader, watcher: watcher}
+}
+
+func (l *WatchLoader) Notify(fn func(names ...string)) func() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.subscribers == nil {
+		l.subscribers = make(map[uint64]func(names ...string))
+	}
+	id := l.next
+	l.next++
+	l.subscribers[id] = fn
+
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		delete(l.subscribers, id)
+	}
+}
+
+// Notifying reports whether Run is watching for changes. Until the watch is established
+// and once Run returns, freshness has to be checked.
+func (l *WatchLoader) Notifying() bool {
+	return l.running.Load() > 0
+}
+
+// Run watches for changes until ctx is done or the watcher fails. Subscribers are told that
+// any template may have changed when the watch is established and when it stops.
+func (l *WatchLoader) Run(ctx context.Context) error {
+	var watching bool
+	defer func() {
+		if watching {
+			l.running.Add(-1)
+		}
+		l.changed()
+	}()
+
+	return l.watcher.Watch(ctx, func(files ...string) {
+		if len(files) == 0 {
+			l.changed()
+			if !watching {
+				watching = true
+				l.running.Add(1)
+			}
+			return
+		}
+
+		if names := l.FileSystemLoader.names(files...); len(names) > 0 {
+			l.changed(names...)
+		}
+	})
+}
+
+// changed drops the cached lookups of names, or all of them without names, and notifies the subscribers
+func (l *WatchLoader) changed(names ...string) {
+	if len(names) == 0 {
+		l.FileSystemLoader.Reset()
+	} else {
+		l.FileSystemLoader.forget(names...)
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for _, fn := range l.subscribers {
+		fn(names...)
+	}
+}
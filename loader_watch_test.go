@@ -0,0 +1,197 @@
+package et_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	et "github.com/gowool/extends-template"
+)
+
+func render(env *et.Environment, name string) string {
+	var out bytes.Buffer
+	if err := env.Render(context.TODO(), &out, name, nil); err != nil {
+		return err.Error()
+	}
+	return out.String()
+}
+
+func TestWatchLoader_Run(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"base/layout.html":        `<body>{{block "content" .}}{{end}}</body>`,
+		"main/views/home.html":    `{{extends "layout.html"}}{{define "content"}}home{{end}}`,
+		"main/views/about.html":   `about`,
+		"main/partials/nav.html":  `nav`,
+		"main/views/partial.html": `{{template "partials/nav.html"}}`,
+	}
+	for file, code := range files {
+		if !assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, file)), 0o755)) ||
+			!assert.NoError(t, os.WriteFile(filepath.Join(dir, file), []byte(code), 0o644)) {
+			return
+		}
+	}
+
+	fsLoader := et.NewFileSystemLoader(os.DirFS(dir))
+	if !assert.NoError(t, fsLoader.SetPaths("test_ns", "main", "base")) {
+		return
+	}
+
+	loader := et.NewWatchLoader(fsLoader, et.NewPollWatcher(os.DirFS(dir), 10*time.Millisecond))
+	env := et.NewEnvironment(loader)
+
+	assert.Equal(t, "<body>home</body>", render(env, "@test_ns/views/home.html"))
+	assert.Equal(t, "about", render(env, "@test_ns/views/about.html"))
+	assert.Equal(t, "nav", render(env, "@test_ns/views/partial.html"))
+
+	// until the watcher runs freshness is polled
+	future := time.Now().Add(time.Hour)
+	assert.False(t, loader.Notifying())
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "base", "layout.html"), []byte(`<main>{{block "content" .}}{{end}}</main>`), 0o644))
+	assert.NoError(t, os.Chtimes(filepath.Join(dir, "base", "layout.html"), future, future))
+	assert.Equal(t, "<main>home</main>", render(env, "@test_ns/views/home.html"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- loader.Run(ctx)
+	}()
+	assert.Eventually(t, loader.Notifying, 2*time.Second, 10*time.Millisecond)
+
+	// a new file in "main" shadows the one in "base"
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "main", "layout.html"), []byte(`<div>{{block "content" .}}{{end}}</div>`), 0o644))
+	assert.Eventually(t, func() bool {
+		return render(env, "@test_ns/views/home.html") == "<div>home</div>"
+	}, 2*time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "main", "partials", "nav.html"), []byte("new nav"), 0o644))
+	assert.Eventually(t, func() bool {
+		return render(env, "@test_ns/views/partial.html") == "new nav"
+	}, 2*time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+	assert.False(t, loader.Notifying())
+
+	// once the watcher stopped freshness is polled again
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "main", "views", "about.html"), []byte("new about"), 0o644))
+	assert.NoError(t, os.Chtimes(filepath.Join(dir, "main", "views", "about.html"), future, future))
+	assert.Equal(t, "new about", render(env, "@test_ns/views/about.html"))
+}
+
+type failingWatcher struct {
+	stale chan struct{}
+}
+
+func (w failingWatcher) Watch(_ context.Context, fn func(files ...string)) error {
+	fn()
+	<-w.stale
+	return errors.New("watch failed")
+}
+
+func TestWatchLoader_RunFailure(t *testing.T) {
+	fsLoader := et.NewFileSystemLoader(fstest.MapFS{"main/home.html": {Data: []byte("home")}})
+	_ = fsLoader.SetPaths("main", "main")
+
+	watcher := failingWatcher{stale: make(chan struct{})}
+	loader := et.NewWatchLoader(fsLoader, watcher)
+
+	var calls [][]string
+	var mu sync.Mutex
+	loader.Notify(func(names ...string) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, names)
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- loader.Run(context.Background())
+	}()
+	assert.Eventually(t, loader.Notifying, 2*time.Second, 10*time.Millisecond)
+
+	close(watcher.stale)
+	assert.EqualError(t, <-done, "watch failed")
+	assert.False(t, loader.Notifying())
+
+	mu.Lock()
+	defer mu.Unlock()
+	// everything is invalidated once the watch is established and again when it fails
+	assert.Equal(t, [][]string{nil, nil}, calls)
+}
+
+func TestWatchLoader_Unsubscribe(t *testing.T) {
+	fsys := fstest.MapFS{"main/home.html": {Data: []byte("home"), ModTime: time.Now().Add(-time.Hour)}}
+	fsLoader := et.NewFileSystemLoader(fsys)
+	_ = fsLoader.SetPaths("main", "main")
+
+	watcher := failingWatcher{stale: make(chan struct{})}
+	loader := et.NewWatchLoader(fsLoader, watcher)
+
+	var calls, chainCalls int
+	var mu sync.Mutex
+	loader.Notify(func(...string) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+	})()
+	et.NewChainLoader(loader).Notify(func(...string) {
+		mu.Lock()
+		defer mu.Unlock()
+		chainCalls++
+	})()
+
+	env := et.NewEnvironment(loader)
+	assert.Equal(t, "home", render(env, "@main/home.html"))
+	env.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- loader.Run(context.Background())
+	}()
+	assert.Eventually(t, loader.Notifying, 2*time.Second, 10*time.Millisecond)
+
+	// the watcher misses the change, a closed environment checks freshness itself
+	fsys["main/home.html"] = &fstest.MapFile{Data: []byte("new home"), ModTime: time.Now().Add(time.Hour)}
+	assert.Equal(t, "new home", render(env, "@main/home.html"))
+
+	close(watcher.stale)
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Zero(t, calls)
+	assert.Zero(t, chainCalls)
+}
+
+func TestChainLoader_Notify(t *testing.T) {
+	fsLoader := newFilesystemLoader()
+	watchLoader := et.NewWatchLoader(fsLoader, et.NewPollWatcher(os.DirFS("./tests"), time.Hour))
+	embedLoader, _ := et.NewEmbedLoader(embedFS)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = watchLoader.Run(ctx)
+	}()
+	assert.Eventually(t, watchLoader.Notifying, 2*time.Second, 10*time.Millisecond)
+
+	assert.True(t, et.NewChainLoader(watchLoader).Notifying())
+	assert.True(t, et.NewChainLoader(watchLoader, embedLoader).Notifying())
+	assert.False(t, et.NewChainLoader(watchLoader, et.NewMemoryLoader(nil)).Notifying())
+	assert.False(t, et.NewChainLoader().Notifying())
+
+	cancel()
+	assert.Eventually(t, func() bool {
+		return !et.NewChainLoader(watchLoader).Notifying()
+	}, 2*time.Second, 10*time.Millisecond)
+}
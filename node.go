@@ -1,10 +1,12 @@
 package et
 
 import (
-	"bytes"
 	"context"
-	"html/template"
+	"errors"
+	"fmt"
 	"path"
+	"slices"
+	"strconv"
 
 	"github.com/gowool/extends-template/internal"
 )
@@ -14,19 +16,52 @@ type Handler func(ctx context.Context, node *Node, namespace string) error
 type Node struct {
 	name      string
 	w         *TemplateWrapper
+	chain     []string
+	orig      []byte
+	edits     []internal.Edit
+	parsedAs  string
+	defines   []string
+	parents   []string
+	unbound   bool
 	Source    *Source
 	Extends   *Node
 	Successor *Node
 	Includes  []*Node
 }
 
-func NewNode(name string, w *TemplateWrapper, successor *Node) *Node {
-	if w.ns != "" && '@' == w.ns[0] && '@' != name[0] {
-		name = w.ns + name
+// parseState is shared by all nodes initialised for one parse
+type parseState struct {
+	// defined are the names of the templates defined so far
+	defined map[string]struct{}
+
+	// exprs are the render time extends expressions met
+	exprs map[layoutExpr]struct{}
+
+	// missing are the candidate layouts skipped because they do not exist
+	missing map[string]struct{}
+}
+
+// layoutExpr is an extends expression of a template, evaluated at render time
+type layoutExpr struct {
+	from string
+	expr string
+}
+
+// missingLayout is the value of an extends expression naming a template which does not exist,
+// it is not a valid template name
+const missingLayout = "\x00"
+
+func newParseState() *parseState {
+	return &parseState{
+		defined: make(map[string]struct{}),
+		exprs:   make(map[layoutExpr]struct{}),
+		missing: make(map[string]struct{}),
 	}
+}
 
+func NewNode(name string, w *TemplateWrapper, successor *Node) *Node {
 	n := &Node{
-		name:      name,
+		name:      w.resolve(name),
 		w:         w,
 		Successor: successor,
 	}
@@ -38,6 +73,16 @@ func NewNode(name string, w *TemplateWrapper, successor *Node) *Node {
 	return n
 }
 
+// Name returns the resolved template name of the node
+func (n *Node) Name() string {
+	return n.name
+}
+
+// Chain returns the extends and include chain from the entry template to the node
+func (n *Node) Chain() []string {
+	return slices.Clone(n.chain)
+}
+
 func (n *Node) SelfParent() *Node {
 	if n.Extends == nil {
 		return n
@@ -45,33 +90,103 @@ func (n *Node) SelfParent() *Node {
 	return n.Extends.SelfParent()
 }
 
-func (n *Node) Init(ctx context.Context) (err error) {
+func (n *Node) Init(ctx context.Context) error {
+	return n.init(ctx, nil, newParseState())
+}
+
+// init loads the node source and resolves its extends and includes. Template calls of names
+// defined anywhere in the inheritance chain so far are not treated as includes. A parent action
+// inside a define or block is rewritten to call the definition it overrides, the one of the
+// closest layout defining the same template.
+func (n *Node) init(ctx context.Context, chain []string, state *parseState) (err error) {
+	if slices.Contains(chain, n.name) {
+		return &CycleError{Chain: append(slices.Clone(chain), n.name)}
+	}
+	chain = append(slices.Clip(chain), n.name)
+	n.chain = chain
+
 	if n.Source, err = n.w.loader.Get(ctx, n.name); err != nil {
 		return
 	}
+	n.orig = n.Source.Code
+
+	d, err := internal.Scan(n.name, n.Source.Code, n.w.left, n.w.right)
+	if err != nil {
+		return &ParseError{Name: n.name, Err: locate(map[string]*Node{n.name: n}, err)}
+	}
+
+	for _, name := range d.Defines {
+		state.defined[name] = struct{}{}
+	}
 
-	n.w.names.Store(n.name, struct{}{})
+	n.defines = d.Defines
 
-	if extends := n.w.reExtends.FindAllSubmatch(n.Source.Code, -1); len(extends) > 0 {
-		n.Source.Code = n.w.reExtends.ReplaceAll(n.Source.Code, []byte{})
-		if err = NewNode(internal.String(extends[0][1]), n.w, n).Init(ctx); err != nil {
+	var edits []internal.Edit
+
+	if d.Extends != nil {
+		edits = append(edits, internal.Blank(n.Source.Code, d.ExtendsPos, d.ExtendsEnd))
+		var layout string
+		if layout, err = n.w.resolver().layout(ctx, n.name, d.Extends, state); err != nil {
+			return n.refError(err)
+		}
+		if layout == "" {
+			n.unbound = true
+		} else if err = NewNode(layout, n.w, n).init(ctx, chain, state); err != nil {
 			return
 		}
 	}
 
-	if includes := n.w.reTemplates.FindAllSubmatch(n.Source.Code, -1); len(includes) > 0 {
-		for _, tpl := range includes {
-			include := NewNode(internal.String(tpl[1]), n.w, nil)
-			if err = include.Init(ctx); err != nil {
+	for _, p := range d.Parents {
+		ancestor := n.Extends
+		for ancestor != nil && !slices.Contains(ancestor.defines, p.Define) {
+			ancestor = ancestor.Extends
+		}
+
+		if ancestor == nil && n.isUnbound() {
+			// the layout is chosen at render time, until then the parent is empty
+			edits = append(edits, internal.Edit{Pos: p.Pos, End: p.End, Text: `""`})
+			continue
+		}
+
+		if ancestor == nil {
+			return n.errorAt(p.Pos, fmt.Errorf("no parent template defines %q", p.Define))
+		}
+
+		if !slices.Contains(ancestor.parents, p.Define) {
+			ancestor.parents = append(ancestor.parents, p.Define)
+		}
+		edits = append(edits, internal.Edit{Pos: p.Pos, End: p.End, Text: "template " + strconv.Quote(ancestor.parentName(p.Define)) + " ."})
+	}
+
+	includes := make(map[string]*Node)
+	for _, directive := range d.Includes {
+		if _, ok := state.defined[directive.Name]; ok {
+			continue
+		}
+
+		var name string
+		if name, err = n.w.resolver().name(n.name, directive); err != nil {
+			return n.refError(err)
+		}
+
+		include, ok := includes[name]
+		if !ok {
+			include = NewNode(name, n.w, nil)
+			if err = include.init(ctx, chain, state); err != nil {
 				return
 			}
+			includes[name] = include
 			n.Includes = append(n.Includes, include)
-			if n.w.ns != "" && '@' == n.w.ns[0] && '@' != rune(tpl[1][0]) {
-				n.Source.Code = bytes.Replace(n.Source.Code, tpl[1], append(internal.Bytes(n.w.ns), tpl[1]...), 1)
-			}
+		}
+
+		if include.name != directive.Name {
+			edits = append(edits, internal.Edit{Pos: directive.Pos, End: directive.End, Text: strconv.Quote(include.name)})
 		}
 	}
 
+	n.edits = edits
+	n.Source.Code = internal.Apply(n.Source.Code, edits)
+
 	for _, h := range n.w.handlers {
 		if err = h(ctx, n, n.w.ns); err != nil {
 			return
@@ -81,11 +196,35 @@ func (n *Node) Init(ctx context.Context) (err error) {
 	return
 }
 
-func (n *Node) Parse(t *template.Template) error {
-	if _, err := t.Parse(internal.String(n.Source.Code)); err != nil {
+// errorAt returns a parse error located at a byte offset of the node source
+func (n *Node) errorAt(pos int, err error) error {
+	line, col := internal.Position(n.orig, pos)
+	return &ParseError{Name: n.name, Err: newTemplateError(n, line, col, err.Error(), err)}
+}
+
+// refError locates an invalid reference of the node source
+func (n *Node) refError(err error) error {
+	var refErr *refError
+	if errors.As(err, &refErr) {
+		return n.errorAt(refErr.pos, refErr.err)
+	}
+	return err
+}
+
+func (n *Node) Parse(t Template) error {
+	n.parsedAs = t.Name()
+
+	if err := t.Parse(internal.String(n.Source.Code)); err != nil {
 		return err
 	}
 
+	// keep the definitions overridden by successors which call them with parent
+	for _, define := range n.parents {
+		if err := t.AddParseTree(n.parentName(define), t.Lookup(define).Tree().Copy()); err != nil {
+			return err
+		}
+	}
+
 	for _, include := range n.Includes {
 		if err := include.SelfParent().Parse(t.New(include.Source.Name)); err != nil {
 			return err
@@ -104,3 +243,43 @@ func (n *Node) Parse(t *template.Template) error {
 
 	return n.Successor.Parse(t.New(name))
 }
+
+// isUnbound reports whether the node or one of its layouts extends a layout chosen at render time
+// which is not known yet
+func (n *Node) isUnbound() bool {
+	for ; n != nil; n = n.Extends {
+		if n.unbound {
+			return true
+		}
+	}
+	return false
+}
+
+// parentName returns the name under which the node definition of a template is kept for parent actions
+func (n *Node) parentName(define string) string {
+	return n.name + "#" + define
+}
+
+// Walk calls fn for the node, its includes with their layouts and its successors, depth first.
+// A template used in several places of the tree is visited once per use.
+func (n *Node) Walk(fn func(n *Node)) {
+	fn(n)
+
+	for _, include := range n.Includes {
+		include.SelfParent().Walk(fn)
+	}
+
+	if n.Successor != nil {
+		n.Successor.Walk(fn)
+	}
+}
+
+// position maps a line and a 0-based column of the parsed code back to the original source
+func (n *Node) position(line, col int) (int, int) {
+	if col < 0 || len(n.edits) == 0 {
+		return line, col
+	}
+
+	offset := internal.Offset(n.Source.Code, line, col)
+	return internal.Position(n.orig, internal.Origin(n.edits, offset))
+}
@@ -0,0 +1,119 @@
+package et
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gowool/extends-template/internal"
+)
+
+// resolver resolves the templates referenced by the templates of one entry template. It is
+// shared by TemplateWrapper.Parse and Lint, so that both resolve names alike.
+type resolver struct {
+	loader Loader
+
+	// ns is the namespace prefix of the entry template, like "@main/", or empty
+	ns string
+
+	// values are the layouts the render time extends expressions evaluated to
+	values map[layoutExpr]string
+}
+
+// refError is an invalid reference at a byte offset of the referencing template
+type refError struct {
+	pos int
+	err error
+}
+
+func (e *refError) Error() string {
+	return e.err.Error()
+}
+
+func (e *refError) Unwrap() error {
+	return e.err
+}
+
+// name resolves the template referenced by a directive of the template from
+func (r *resolver) name(from string, directive internal.Directive) (string, error) {
+	name, err := resolveName(from, directive.Name)
+	if err != nil {
+		return "", &refError{pos: directive.Pos, err: err}
+	}
+	return qualify(r.ns, name), nil
+}
+
+// layout returns the first candidate layout of an extends action of the template from which
+// exists. Candidates evaluated at render time take the layouts of values and are skipped when
+// unknown or empty, so that a template with only such candidates is parsed standalone.
+func (r *resolver) layout(ctx context.Context, from string, candidates []internal.Directive, state *parseState) (string, error) {
+	var tried []string
+	for _, c := range candidates {
+		if c.Expr != "" {
+			x := layoutExpr{from: from, expr: c.Expr}
+			state.exprs[x] = struct{}{}
+
+			switch c.Name = r.values[x]; c.Name {
+			case "":
+				continue
+			case missingLayout:
+				tried = append(tried, c.Expr)
+				continue
+			}
+		}
+
+		name, err := r.name(from, c)
+		if err != nil {
+			return "", err
+		}
+
+		if len(candidates) == 1 && c.Expr == "" {
+			return name, nil
+		}
+
+		if ok, _ := r.loader.Exists(ctx, name); ok {
+			return name, nil
+		}
+		state.missing[name] = struct{}{}
+		tried = append(tried, strconv.Quote(name))
+	}
+
+	if len(tried) == 0 {
+		return "", nil
+	}
+	return "", fmt.Errorf("none of the layouts %s extended by \"%s\" exists", strings.Join(tried, ", "), from)
+}
+
+// resolveName resolves a template name referenced by the template from. Names starting with
+// "./" or "../" are relative to the directory of from and must not leave its namespace root,
+// all names are normalised by Name.
+func resolveName(from, name string) (string, error) {
+	if !strings.HasPrefix(name, "./") && !strings.HasPrefix(name, "../") {
+		return Name(name)
+	}
+
+	prefix := namespaceOf(from)
+	resolved := path.Join(path.Dir(from[len(prefix):]), name)
+	if resolved == ".." || strings.HasPrefix(resolved, "../") {
+		return "", invalidName(name, "escapes the namespace root")
+	}
+	return Name(prefix + resolved)
+}
+
+// qualify prefixes a template name with the namespace prefix ns, unless it has a namespace
+func qualify(ns, name string) string {
+	if ns != "" && !strings.HasPrefix(name, "@") {
+		return ns + name
+	}
+	return name
+}
+
+// namespaceOf returns the namespace prefix of a template name, like "@main/", or empty
+func namespaceOf(name string) string {
+	if data := strings.SplitN(name, "/", 2); len(data) == 2 && data[0] != "" && '@' == data[0][0] {
+		return data[0] + "/"
+	}
+	return ""
+}
@@ -0,0 +1,140 @@
+package et
+
+import (
+	htmltemplate "html/template"
+	"io"
+	texttemplate "text/template"
+	"text/template/parse"
+)
+
+var (
+	_ Template = htmlTemplate{}
+	_ Template = textTemplate{}
+)
+
+// Template is a template set backed by html/template or text/template
+type Template interface {
+	// Name returns the name of the template
+	Name() string
+
+	// New allocates a new template associated with the given one
+	New(name string) Template
+
+	// Parse parses text as a template body
+	Parse(text string) error
+
+	// Clone returns a duplicate of the template, including all associated templates
+	Clone() (Template, error)
+
+	// Lookup returns the template with the given name, or nil if there is no such template
+	Lookup(name string) Template
+
+	// Tree returns the parse tree of the template, or nil if it has not been parsed
+	Tree() *parse.Tree
+
+	// AddParseTree associates the parse tree with the template under the given name
+	AddParseTree(name string, tree *parse.Tree) error
+
+	// ExecuteTemplate applies the template with the given name to data and writes the output to wr
+	ExecuteTemplate(wr io.Writer, name string, data any) error
+}
+
+// WrapHTML returns a Template backed by an html/template set
+func WrapHTML(t *htmltemplate.Template) Template {
+	return htmlTemplate{t: t}
+}
+
+// WrapText returns a Template backed by a text/template set
+func WrapText(t *texttemplate.Template) Template {
+	return textTemplate{t: t}
+}
+
+type htmlTemplate struct {
+	t *htmltemplate.Template
+}
+
+func (t htmlTemplate) Name() string {
+	return t.t.Name()
+}
+
+func (t htmlTemplate) New(name string) Template {
+	return htmlTemplate{t: t.t.New(name)}
+}
+
+func (t htmlTemplate) Parse(text string) error {
+	_, err := t.t.Parse(text)
+	return err
+}
+
+func (t htmlTemplate) Clone() (Template, error) {
+	c, err := t.t.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return htmlTemplate{t: c}, nil
+}
+
+func (t htmlTemplate) Lookup(name string) Template {
+	if l := t.t.Lookup(name); l != nil {
+		return htmlTemplate{t: l}
+	}
+	return nil
+}
+
+func (t htmlTemplate) Tree() *parse.Tree {
+	return t.t.Tree
+}
+
+func (t htmlTemplate) AddParseTree(name string, tree *parse.Tree) error {
+	_, err := t.t.AddParseTree(name, tree)
+	return err
+}
+
+func (t htmlTemplate) ExecuteTemplate(wr io.Writer, name string, data any) error {
+	return t.t.ExecuteTemplate(wr, name, data)
+}
+
+type textTemplate struct {
+	t *texttemplate.Template
+}
+
+func (t textTemplate) Name() string {
+	return t.t.Name()
+}
+
+func (t textTemplate) New(name string) Template {
+	return textTemplate{t: t.t.New(name)}
+}
+
+func (t textTemplate) Parse(text string) error {
+	_, err := t.t.Parse(text)
+	return err
+}
+
+func (t textTemplate) Clone() (Template, error) {
+	c, err := t.t.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return textTemplate{t: c}, nil
+}
+
+func (t textTemplate) Lookup(name string) Template {
+	if l := t.t.Lookup(name); l != nil {
+		return textTemplate{t: l}
+	}
+	return nil
+}
+
+func (t textTemplate) Tree() *parse.Tree {
+	return t.t.Tree
+}
+
+func (t textTemplate) AddParseTree(name string, tree *parse.Tree) error {
+	_, err := t.t.AddParseTree(name, tree)
+	return err
+}
+
+func (t textTemplate) ExecuteTemplate(wr io.Writer, name string, data any) error {
+	return t.t.ExecuteTemplate(wr, name, data)
+}
@@ -1,98 +1,401 @@
 package et
 
 import (
+	"bytes"
+	"cmp"
 	"context"
-	"html/template"
-	"regexp"
+	"errors"
+	htmltemplate "html/template"
+	"io"
 	"slices"
-	"strings"
-	"sync"
 	"sync/atomic"
+	texttemplate "text/template"
 	"time"
+
+	"github.com/gowool/extends-template/internal"
 )
 
 type TemplateWrapper struct {
-	HTML        *template.Template
-	orig        *template.Template
-	reExtends   *regexp.Regexp
-	reTemplates *regexp.Regexp
-	names       *sync.Map
-	parsed      atomic.Bool
-	unix        atomic.Int64
-	loader      Loader
-	handlers    []Handler
-	global      []string
-	ns          string
+	name     string
+	hash     string
+	orig     Template
+	left     string
+	right    string
+	snapshot atomic.Pointer[Snapshot]
+	loader   Loader
+	handlers []Handler
+	global   []string
+	ns       string
+	values   map[layoutExpr]string
+}
+
+// Snapshot is an immutable result of TemplateWrapper.Parse: a template set together
+// with the dependencies it was built from
+type Snapshot struct {
+	name        string
+	tmpl        Template
+	root        *Node
+	names       map[string]struct{}
+	nodes       map[string]*Node
+	exprs       []layoutExpr
+	missing     map[string]struct{}
+	versions    map[string]string
+	unixNano    int64
+	size        int64
+	fingerprint string
+	modTime     time.Time
+}
+
+// Template returns the template set regardless of the underlying engine
+func (s *Snapshot) Template() Template {
+	return s.tmpl
+}
+
+// HTML returns the html/template set or nil if the snapshot was built with text/template
+func (s *Snapshot) HTML() *htmltemplate.Template {
+	if t, ok := s.tmpl.(htmlTemplate); ok {
+		return t.t
+	}
+	return nil
+}
+
+// Text returns the text/template set or nil if the snapshot was built with html/template
+func (s *Snapshot) Text() *texttemplate.Template {
+	if t, ok := s.tmpl.(textTemplate); ok {
+		return t.t
+	}
+	return nil
+}
+
+// Root returns the resolved template tree: the topmost layout of the entry template, whose
+// successors lead down to the entry template. Globals are the first includes of the root.
+// The tree must not be modified.
+func (s *Snapshot) Root() *Node {
+	return s.root
+}
+
+// Names returns the sorted names of all templates the snapshot depends on
+func (s *Snapshot) Names() []string {
+	names := make([]string, 0, len(s.names))
+	for name := range s.names {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func (s *Snapshot) locate(err error) error {
+	var htmlErr *htmltemplate.Error
+	if errors.As(err, &htmlErr) && htmlErr.Node == nil && htmlErr.Line != 0 {
+		if t := s.HTML().Lookup(htmlErr.Name); t != nil && t.Tree != nil {
+			if n, ok := s.nodes[t.Tree.ParseName]; ok {
+				return newTemplateError(n, htmlErr.Line, -1, htmlErr.Description, err)
+			}
+		}
+	}
+	return locate(s.nodes, err)
+}
+
+// Execute applies the entry template of the snapshot to data and writes the output to wr
+func (s *Snapshot) Execute(wr io.Writer, data any) error {
+	return s.execute(wr, s.name, "", data)
+}
+
+// ExecuteBlock applies the named block of the snapshot to data and writes the output to wr
+func (s *Snapshot) ExecuteBlock(wr io.Writer, block string, data any) error {
+	return s.execute(wr, block, block, data)
+}
+
+func (s *Snapshot) execute(wr io.Writer, name, block string, data any) error {
+	if err := s.tmpl.ExecuteTemplate(wr, name, data); err != nil {
+		return &ExecuteError{Name: s.name, Block: block, Err: s.locate(err)}
+	}
+	return nil
+}
+
+// Fingerprint returns a stable hash of all dependency sources and the environment settings,
+// suitable as an HTTP entity tag
+func (s *Snapshot) Fingerprint() string {
+	return s.fingerprint
+}
+
+// ModTime returns the latest modification time of all dependencies,
+// or zero if the loader does not report modification times
+func (s *Snapshot) ModTime() time.Time {
+	return s.modTime
+}
+
+// UnixNano returns the time the snapshot was parsed at
+func (s *Snapshot) UnixNano() int64 {
+	return s.unixNano
+}
+
+// Size returns the total size of the dependency sources, an estimate of the snapshot memory
+func (s *Snapshot) Size() int64 {
+	return s.size
+}
+
+// Version returns the version the loader reported for a dependency at parse time
+func (s *Snapshot) Version(name string) string {
+	return s.versions[name]
 }
 
 func NewTemplateWrapper(
-	html *template.Template,
+	html *htmltemplate.Template,
+	loader Loader,
+	handlers []Handler,
+	left, right string,
+	global ...string,
+) *TemplateWrapper {
+	return newTemplateWrapper(WrapHTML(html), loader, handlers, left, right, global...)
+}
+
+func NewTextTemplateWrapper(
+	text *texttemplate.Template,
+	loader Loader,
+	handlers []Handler,
+	left, right string,
+	global ...string,
+) *TemplateWrapper {
+	return newTemplateWrapper(WrapText(text), loader, handlers, left, right, global...)
+}
+
+func newTemplateWrapper(
+	tmpl Template,
 	loader Loader,
 	handlers []Handler,
-	reExtends *regexp.Regexp,
-	reTemplate *regexp.Regexp,
+	left, right string,
 	global ...string,
 ) *TemplateWrapper {
 	w := &TemplateWrapper{
-		HTML:        html,
-		loader:      loader,
-		handlers:    handlers,
-		reExtends:   reExtends,
-		reTemplates: reTemplate,
-		global:      global,
+		name:     tmpl.Name(),
+		orig:     tmpl,
+		loader:   loader,
+		handlers: handlers,
+		left:     left,
+		right:    right,
+		global:   global,
 	}
 
-	if data := strings.SplitN(html.Name(), "/", 2); len(data) == 2 && '@' == data[0][0] {
-		w.ns = data[0] + "/"
-	}
+	w.ns = namespaceOf(w.name)
 
 	return w
 }
 
-func (w *TemplateWrapper) IsFresh(ctx context.Context) (ok bool) {
-	if !w.parsed.Load() {
+// Name returns the name of the entry template
+func (w *TemplateWrapper) Name() string {
+	return w.name
+}
+
+// resolve prefixes a template name with the namespace of the entry template, unless it has one
+func (w *TemplateWrapper) resolve(name string) string {
+	return qualify(w.ns, name)
+}
+
+func (w *TemplateWrapper) resolver() *resolver {
+	return &resolver{loader: w.loader, ns: w.ns, values: w.values}
+}
+
+// Snapshot returns the last successfully parsed state or nil if the wrapper has not been parsed yet
+func (w *TemplateWrapper) Snapshot() *Snapshot {
+	return w.snapshot.Load()
+}
+
+// Template returns the current template set regardless of the underlying engine
+func (w *TemplateWrapper) Template() Template {
+	if s := w.snapshot.Load(); s != nil {
+		return s.Template()
+	}
+	return nil
+}
+
+// HTML returns the current html/template set
+func (w *TemplateWrapper) HTML() *htmltemplate.Template {
+	if s := w.snapshot.Load(); s != nil {
+		return s.HTML()
+	}
+	return nil
+}
+
+// Text returns the current text/template set
+func (w *TemplateWrapper) Text() *texttemplate.Template {
+	if s := w.snapshot.Load(); s != nil {
+		return s.Text()
+	}
+	return nil
+}
+
+func (w *TemplateWrapper) IsFresh(ctx context.Context) bool {
+	s := w.snapshot.Load()
+	if s == nil {
 		if err := w.Parse(ctx); err != nil {
-			return ok
+			return false
 		}
+		s = w.snapshot.Load()
 	}
 
-	unix := w.unix.Load()
-	w.names.Range(func(key, _ any) bool {
-		ok, _ = w.loader.IsFresh(ctx, key.(string), unix)
-		return ok
-	})
-	return
+	for name := range s.missing {
+		if ok, _ := w.loader.Exists(ctx, name); ok {
+			return false
+		}
+	}
+
+	versioner, _ := w.loader.(Versioner)
+
+	for name := range s.names {
+		if version := s.versions[name]; versioner != nil && version != "" {
+			if current, err := versioner.Version(ctx, name); err != nil || current != version {
+				return false
+			}
+		} else if ok, _ := w.loader.IsFresh(ctx, name, time.Unix(0, s.unixNano).Unix()); !ok {
+			return false
+		}
+	}
+	return true
 }
 
-func (w *TemplateWrapper) Parse(ctx context.Context) (err error) {
-	defer func() {
-		w.parsed.Store(true)
-		w.unix.Store(time.Now().Unix())
-	}()
+func (w *TemplateWrapper) dependsOn(names ...string) bool {
+	s := w.snapshot.Load()
+	if s == nil {
+		return true
+	}
 
-	if w.orig == nil {
-		if w.orig, err = w.HTML.Clone(); err != nil {
-			return
+	for _, name := range names {
+		if _, ok := s.names[name]; ok || name == w.name {
+			return true
+		}
+		if _, ok := s.missing[name]; ok {
+			return true
 		}
-	} else if w.HTML, err = w.orig.Clone(); err != nil {
-		return
+	}
+	return false
+}
+
+// Parse builds a new template set and atomically publishes it as the current snapshot.
+// On failure the previous snapshot is kept.
+func (w *TemplateWrapper) Parse(ctx context.Context) error {
+	unixNano := time.Now().UnixNano()
+
+	tmpl, err := w.orig.Clone()
+	if err != nil {
+		return &ParseError{Name: w.name, Err: err}
 	}
 
-	w.names = new(sync.Map)
+	state := newParseState()
 
-	node := NewNode(w.HTML.Name(), w, nil)
-	if err = node.Init(ctx); err != nil {
-		return
+	globals := make([]*Node, 0, len(w.global))
+	for _, name := range w.global {
+		globalNode := NewNode(name, w, nil)
+		if err = globalNode.init(ctx, nil, state); err != nil {
+			return w.loadError(err)
+		}
+		globals = append(globals, globalNode)
+	}
+
+	node := NewNode(w.name, w, nil)
+	if err = node.init(ctx, nil, state); err != nil {
+		return w.loadError(err)
 	}
 	node = node.SelfParent()
+	node.Includes = slices.Insert(node.Includes, 0, globals...)
+
+	err = node.Parse(tmpl)
 
-	for i := len(w.global) - 1; i >= 0; i-- {
-		globalNode := NewNode(w.global[i], w, nil)
-		if err = globalNode.Init(ctx); err != nil {
-			return
+	names := make(map[string]struct{})
+	nodes := make(map[string]*Node)
+	sources := make(map[string][]byte)
+	versions := make(map[string]string)
+	var modTime time.Time
+	node.Walk(func(n *Node) {
+		names[n.name] = struct{}{}
+		if n.parsedAs != "" {
+			nodes[n.parsedAs] = n
 		}
-		node.Includes = slices.Insert(node.Includes, 0, globalNode)
+		sources[n.name] = n.orig
+		versions[n.name] = n.Source.Version
+		if n.Source.ModTime.After(modTime) {
+			modTime = n.Source.ModTime
+		}
+	})
+
+	if err != nil {
+		return &ParseError{Name: w.name, Err: locate(nodes, err)}
+	}
+
+	var size int64
+	for _, code := range sources {
+		size += int64(len(code))
+	}
+
+	exprs := make([]layoutExpr, 0, len(state.exprs))
+	for x := range state.exprs {
+		exprs = append(exprs, x)
 	}
+	slices.SortFunc(exprs, compareLayoutExprs)
+
+	w.snapshot.Store(&Snapshot{
+		name:        w.name,
+		tmpl:        tmpl,
+		root:        node,
+		names:       names,
+		nodes:       nodes,
+		exprs:       exprs,
+		missing:     state.missing,
+		versions:    versions,
+		unixNano:    unixNano,
+		size:        size,
+		fingerprint: w.fingerprint(sources),
+		modTime:     modTime,
+	})
+	return nil
+}
+
+func (w *TemplateWrapper) fingerprint(sources map[string][]byte) string {
+	var buf bytes.Buffer
+	buf.WriteString(w.hash)
+
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		buf.WriteByte(0)
+		buf.WriteString(name)
+		buf.WriteByte(0)
+		buf.Write(sources[name])
+	}
+
+	return internal.Hash(buf.Bytes())
+}
+
+func (w *TemplateWrapper) loadError(err error) error {
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		return err
+	}
+	return &LoadError{Name: w.name, Err: err}
+}
+
+// Execute applies the entry template to data and writes the output to wr
+func (w *TemplateWrapper) Execute(wr io.Writer, data any) error {
+	return w.execute(wr, w.name, "", data)
+}
+
+// ExecuteBlock applies the named block of the template set to data and writes the output to wr
+func (w *TemplateWrapper) ExecuteBlock(wr io.Writer, block string, data any) error {
+	return w.execute(wr, block, block, data)
+}
+
+func (w *TemplateWrapper) execute(wr io.Writer, name, block string, data any) error {
+	s := w.snapshot.Load()
+	if s == nil {
+		return &ExecuteError{Name: w.name, Block: block, Err: ErrNotParsed}
+	}
+	return s.execute(wr, name, block, data)
+}
 
-	return node.Parse(w.HTML)
+func compareLayoutExprs(a, b layoutExpr) int {
+	return cmp.Or(cmp.Compare(a.from, b.from), cmp.Compare(a.expr, b.expr))
 }
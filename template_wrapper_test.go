@@ -6,13 +6,13 @@ import (
 	"errors"
 	"fmt"
 	"html/template"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	et "github.com/gowool/extends-template"
-	"github.com/gowool/extends-template/internal"
 )
 
 const (
@@ -84,8 +84,8 @@ func TestTemplateWrapper_IsFresh(t *testing.T) {
 			template.New(name),
 			wrapLoader{t: s.t},
 			s.handlers,
-			internal.ReExtends("{{", "}}"),
-			internal.ReTemplate("{{", "}}"))
+			"{{",
+			"}}")
 
 		isFresh := wrapper.IsFresh(context.TODO())
 
@@ -123,8 +123,8 @@ func TestTemplateWrapper_Parse(t *testing.T) {
 			template.New(name),
 			wrapLoader{},
 			s.handlers,
-			internal.ReExtends("{{", "}}"),
-			internal.ReTemplate("{{", "}}"),
+			"{{",
+			"}}",
 			"@main/global.html",
 		)
 
@@ -133,12 +133,170 @@ func TestTemplateWrapper_Parse(t *testing.T) {
 
 			if s.isError {
 				assert.Error(t, err)
-			} else if assert.NoError(t, err) && assert.NotNil(t, wrapper.HTML) {
+			} else if assert.NoError(t, err) && assert.NotNil(t, wrapper.HTML()) {
 				var out bytes.Buffer
-				if err = wrapper.HTML.ExecuteTemplate(&out, name, nil); assert.NoError(t, err) {
+				if err = wrapper.HTML().ExecuteTemplate(&out, name, nil); assert.NoError(t, err) {
 					assert.Equal(t, htmlResult, out.String())
 				}
 			}
 		}
 	}
 }
+
+func TestTemplateWrapper_ParseConcurrent(t *testing.T) {
+	name := "@main/view.html"
+	wrapper := et.NewTemplateWrapper(
+		template.New(name),
+		wrapLoader{},
+		nil,
+		"{{",
+		"}}",
+		"@main/global.html",
+	)
+	if !assert.NoError(t, wrapper.Parse(context.TODO())) {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for i := range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for range 50 {
+				if i%4 == 0 {
+					assert.NoError(t, wrapper.Parse(context.TODO()))
+					continue
+				}
+
+				snapshot := wrapper.Snapshot()
+				assert.Contains(t, snapshot.Names(), "@main/layout.html")
+
+				var out bytes.Buffer
+				if err := wrapper.Execute(&out, nil); assert.NoError(t, err) {
+					assert.Equal(t, htmlResult, out.String())
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestTemplateWrapper_ExecuteNotParsed(t *testing.T) {
+	wrapper := et.NewTemplateWrapper(
+		template.New("@main/view.html"),
+		wrapLoader{},
+		nil,
+		"{{",
+		"}}",
+	)
+
+	assert.Nil(t, wrapper.Snapshot())
+	assert.ErrorIs(t, wrapper.Execute(&bytes.Buffer{}, nil), et.ErrNotParsed)
+}
+
+func TestTemplateWrapper_Fingerprint(t *testing.T) {
+	loader := et.NewMemoryLoader(map[string][]byte{
+		"layout.html": []byte(htmlLayout),
+		"view.html":   []byte(`{{extends "layout.html"}}{{define "content"}}view{{end}}`),
+	})
+	env := et.NewEnvironment(loader)
+
+	w, err := env.Load(context.TODO(), "view.html")
+	if !assert.NoError(t, err) {
+		return
+	}
+	fingerprint := w.Snapshot().Fingerprint()
+	modTime := w.Snapshot().ModTime()
+
+	assert.NotEmpty(t, fingerprint)
+	assert.False(t, modTime.IsZero())
+
+	if assert.NoError(t, w.Parse(context.TODO())) {
+		assert.Equal(t, fingerprint, w.Snapshot().Fingerprint())
+	}
+
+	loader.Add("layout.html", []byte(`<main>{{block "content" .}}{{end}}</main>`))
+
+	if assert.NoError(t, w.Parse(context.TODO())) {
+		assert.NotEqual(t, fingerprint, w.Snapshot().Fingerprint())
+		assert.True(t, w.Snapshot().ModTime().After(modTime))
+	}
+
+	other, err := et.NewEnvironment(loader).Funcs(template.FuncMap{"f": func() string { return "" }}).Load(context.TODO(), "view.html")
+	if assert.NoError(t, err) {
+		assert.NotEqual(t, w.Snapshot().Fingerprint(), other.Snapshot().Fingerprint())
+	}
+}
+
+func TestSnapshot_Root(t *testing.T) {
+	wrapper := et.NewTemplateWrapper(
+		template.New("@main/view.html"),
+		wrapLoader{},
+		nil,
+		"{{",
+		"}}",
+		"@main/global.html",
+	)
+	if !assert.NoError(t, wrapper.Parse(context.TODO())) {
+		return
+	}
+
+	root := wrapper.Snapshot().Root()
+	if !assert.NotNil(t, root) {
+		return
+	}
+
+	assert.Equal(t, "@main/layout.html", root.Name())
+	assert.Nil(t, root.Extends)
+	if assert.Len(t, root.Includes, 1) {
+		assert.Equal(t, "@main/global.html", root.Includes[0].Name())
+	}
+
+	view := root.Successor
+	if assert.NotNil(t, view) {
+		assert.Equal(t, "@main/view.html", view.Name())
+		assert.Same(t, root, view.Extends)
+		assert.Nil(t, view.Successor)
+		assert.Len(t, view.Includes, 2)
+	}
+
+	var names []string
+	root.Walk(func(n *et.Node) {
+		names = append(names, n.Name())
+	})
+	assert.Equal(t, []string{
+		"@main/layout.html",
+		"@main/global.html",
+		"@main/view.html",
+		"@main/title.html",
+		"@main/subtitle.html",
+	}, names)
+}
+
+func TestSnapshot_Execute(t *testing.T) {
+	loader := et.NewMemoryLoader(map[string][]byte{
+		"layout.html": []byte(htmlLayout),
+		"view.html":   []byte(`{{extends "layout.html"}}{{define "content"}}view{{end}}`),
+	})
+	env := et.NewEnvironment(loader).Debug(true)
+
+	w, err := env.Load(context.TODO(), "view.html")
+	if !assert.NoError(t, err) {
+		return
+	}
+	s := w.Snapshot()
+
+	loader.Add("view.html", []byte(`{{extends "layout.html"}}{{define "content"}}changed{{end}}`))
+	if _, err = env.Load(context.TODO(), "view.html"); !assert.NoError(t, err) {
+		return
+	}
+
+	// the snapshot keeps the template set it was parsed with
+	var before, after bytes.Buffer
+	if assert.NoError(t, s.ExecuteBlock(&before, "content", nil)) && assert.NoError(t, w.ExecuteBlock(&after, "content", nil)) {
+		assert.Equal(t, "view", before.String())
+		assert.Equal(t, "changed", after.String())
+	}
+	assert.NoError(t, s.Execute(&bytes.Buffer{}, nil))
+}
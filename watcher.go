@@ -0,0 +1,110 @@
+package et
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"time"
+)
+
+const DefaultPollInterval = time.Second
+
+// Watcher reports changes of template files
+type Watcher interface {
+	// Watch blocks until ctx is done, calling fn with the slash-separated paths of created,
+	// modified and removed files relative to the watched root. fn is called without files
+	// once the watch is established and whenever changes may have been missed.
+	Watch(ctx context.Context, fn func(files ...string)) error
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// PollWatcher detects changes of an arbitrary fs.FS by walking it periodically
+type PollWatcher struct {
+	fsys     fs.FS
+	interval time.Duration
+}
+
+func NewPollWatcher(fsys fs.FS, interval time.Duration) *PollWatcher {
+	if fsys == nil {
+		panic("fs.FS is nil")
+	}
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	return &PollWatcher{fsys: fsys, interval: interval}
+}
+
+func (w *PollWatcher) Watch(ctx context.Context, fn func(files ...string)) error {
+	prev, err := w.scan()
+	if err != nil {
+		return err
+	}
+	fn()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		next, err := w.scan()
+		if err != nil {
+			return err
+		}
+
+		var changed []string
+		for file, state := range next {
+			if old, ok := prev[file]; !ok || old != state {
+				changed = append(changed, file)
+			}
+		}
+		for file := range prev {
+			if _, ok := next[file]; !ok {
+				changed = append(changed, file)
+			}
+		}
+		prev = next
+
+		if len(changed) > 0 {
+			fn(changed...)
+		}
+	}
+}
+
+func (w *PollWatcher) scan() (map[string]fileState, error) {
+	files := make(map[string]fileState)
+
+	err := fs.WalkDir(w.fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		files[p] = fileState{modTime: info.ModTime(), size: info.Size()}
+		return nil
+	})
+
+	return files, err
+}
@@ -0,0 +1,162 @@
+//go:build linux
+
+package et
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CLOSE_WRITE | syscall.IN_MODIFY | syscall.IN_ATTRIB |
+	syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO |
+	syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+type notifyWatcher struct {
+	dir string
+}
+
+// NewNotifyWatcher returns a Watcher of dir backed by inotify
+func NewNotifyWatcher(dir string) Watcher {
+	return &notifyWatcher{dir: dir}
+}
+
+func (w *notifyWatcher) Watch(ctx context.Context, fn func(files ...string)) error {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return os.NewSyscallError("inotify_init1", err)
+	}
+
+	f := os.NewFile(uintptr(fd), "inotify")
+
+	var once sync.Once
+	closeFile := func() { once.Do(func() { _ = f.Close() }) }
+	defer closeFile()
+
+	dirs := make(map[int32]string)
+	if _, err = w.add(fd, dirs, "."); err != nil {
+		return err
+	}
+	fn()
+
+	go func() {
+		<-ctx.Done()
+		closeFile()
+	}()
+
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := f.Read(buf)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, os.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		var (
+			changed []string
+			all     bool
+		)
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameBytes := buf[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+int(event.Len)]
+			offset += syscall.SizeofInotifyEvent + int(event.Len)
+
+			// events were dropped, any file may have changed
+			if event.Mask&syscall.IN_Q_OVERFLOW != 0 {
+				all = true
+				continue
+			}
+
+			dir, ok := dirs[event.Wd]
+			if !ok {
+				continue
+			}
+			if event.Mask&(syscall.IN_IGNORED|syscall.IN_DELETE_SELF|syscall.IN_MOVE_SELF) != 0 {
+				if dir == "." {
+					return fmt.Errorf("watched directory \"%s\" was removed or moved", w.dir)
+				}
+				if event.Mask&syscall.IN_IGNORED != 0 {
+					delete(dirs, event.Wd)
+				}
+				continue
+			}
+
+			name := string(nameBytes)
+			if i := strings.IndexByte(name, 0); i >= 0 {
+				name = name[:i]
+			}
+			if name == "" {
+				continue
+			}
+			p := path.Join(dir, name)
+
+			if event.Mask&syscall.IN_ISDIR != 0 {
+				if event.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+					files, err := w.add(fd, dirs, p)
+					if err != nil {
+						return err
+					}
+					changed = append(changed, files...)
+				}
+				if event.Mask&(syscall.IN_DELETE|syscall.IN_MOVED_FROM) != 0 {
+					// the files of the directory are not reported one by one
+					w.remove(fd, dirs, p)
+					all = true
+				}
+				continue
+			}
+
+			changed = append(changed, p)
+		}
+
+		if all {
+			fn()
+		} else if len(changed) > 0 {
+			fn(changed...)
+		}
+	}
+}
+
+// add watches the directory p with all its subdirectories and returns the files found in them
+func (w *notifyWatcher) add(fd int, dirs map[int32]string, p string) (files []string, err error) {
+	err = fs.WalkDir(os.DirFS(w.dir), p, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			files = append(files, p)
+			return nil
+		}
+
+		wd, err := syscall.InotifyAddWatch(fd, filepath.Join(w.dir, filepath.FromSlash(p)), inotifyMask)
+		if err != nil {
+			return os.NewSyscallError("inotify_add_watch", err)
+		}
+		dirs[int32(wd)] = p
+		return nil
+	})
+	return
+}
+
+// remove stops watching the directory p and its subdirectories
+func (w *notifyWatcher) remove(fd int, dirs map[int32]string, p string) {
+	for wd, dir := range dirs {
+		if dir == p || strings.HasPrefix(dir, p+"/") {
+			_, _ = syscall.InotifyRmWatch(fd, uint32(wd))
+			delete(dirs, wd)
+		}
+	}
+}
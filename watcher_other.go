@@ -0,0 +1,10 @@
+//go:build !linux
+
+package et
+
+import "os"
+
+// NewNotifyWatcher returns a Watcher of dir. Without inotify support it polls the directory.
+func NewNotifyWatcher(dir string) Watcher {
+	return NewPollWatcher(os.DirFS(dir), DefaultPollInterval)
+}
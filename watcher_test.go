@@ -0,0 +1,123 @@
+package et_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	et "github.com/gowool/extends-template"
+)
+
+type changes struct {
+	files  []string
+	resets int
+	mu     sync.Mutex
+}
+
+func (c *changes) add(files ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(files) == 0 {
+		c.resets++
+	}
+	c.files = append(c.files, files...)
+}
+
+func (c *changes) isReady() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.resets > 0
+}
+
+func (c *changes) contains(file string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return slices.Contains(c.files, file)
+}
+
+func TestWatcher_Watch(t *testing.T) {
+	scenarios := map[string]func(dir string) et.Watcher{
+		"poll": func(dir string) et.Watcher {
+			return et.NewPollWatcher(os.DirFS(dir), 10*time.Millisecond)
+		},
+		"notify": et.NewNotifyWatcher,
+	}
+
+	for name, newWatcher := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			if !assert.NoError(t, os.MkdirAll(filepath.Join(dir, "main", "views"), 0o755)) {
+				return
+			}
+			if !assert.NoError(t, os.WriteFile(filepath.Join(dir, "main", "views", "home.html"), []byte("home"), 0o644)) {
+				return
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			c := new(changes)
+			done := make(chan error, 1)
+			go func() {
+				done <- newWatcher(dir).Watch(ctx, c.add)
+			}()
+
+			// the watcher reports when it took its initial snapshot or registered its watches
+			assert.Eventually(t, c.isReady, 2*time.Second, 10*time.Millisecond)
+
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, "main", "views", "home.html"), []byte("home changed"), 0o644))
+			assert.Eventually(t, func() bool {
+				return c.contains("main/views/home.html")
+			}, 2*time.Second, 10*time.Millisecond)
+
+			assert.NoError(t, os.MkdirAll(filepath.Join(dir, "main", "partials"), 0o755))
+			time.Sleep(50 * time.Millisecond)
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, "main", "partials", "nav.html"), []byte("nav"), 0o644))
+			assert.Eventually(t, func() bool {
+				return c.contains("main/partials/nav.html")
+			}, 2*time.Second, 10*time.Millisecond)
+
+			assert.NoError(t, os.Remove(filepath.Join(dir, "main", "views", "home.html")))
+			assert.Eventually(t, func() bool {
+				c.mu.Lock()
+				defer c.mu.Unlock()
+
+				return len(c.files) > 0 && c.files[len(c.files)-1] == "main/views/home.html"
+			}, 2*time.Second, 10*time.Millisecond)
+
+			// a renamed directory is reported with its files or as a change of any file
+			assert.NoError(t, os.Rename(filepath.Join(dir, "main", "partials"), filepath.Join(dir, "main", "components")))
+			assert.Eventually(t, func() bool {
+				c.mu.Lock()
+				resets := c.resets
+				c.mu.Unlock()
+
+				return resets > 1 || (c.contains("main/partials/nav.html") && c.contains("main/components/nav.html"))
+			}, 2*time.Second, 10*time.Millisecond)
+
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, "main", "components", "nav.html"), []byte("new nav"), 0o644))
+			time.Sleep(50 * time.Millisecond)
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, "main", "components", "link.html"), []byte("link"), 0o644))
+			assert.Eventually(t, func() bool {
+				return c.contains("main/components/link.html")
+			}, 2*time.Second, 10*time.Millisecond)
+
+			cancel()
+			select {
+			case err := <-done:
+				assert.NoError(t, err)
+			case <-time.After(2 * time.Second):
+				t.Error("watcher did not stop")
+			}
+		})
+	}
+}